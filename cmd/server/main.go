@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/auth"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/cases"
@@ -13,6 +14,9 @@ import (
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/gsi"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/inventory"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/predictions"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/raffles"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/steam"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/stream"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/telegram"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/wallet"
@@ -35,22 +39,36 @@ func main() {
 	authService := auth.NewService(pool, cfg.JWTSecret, cfg.BaseURL)
 	walletService := wallet.NewService(pool)
 	walletHandler := wallet.NewHandler(walletService)
-	inventoryService := inventory.NewService(pool, walletService)
+	rarityOdds, err := inventory.ParseRarityOdds(cfg.CaseRarityOdds)
+	if err != nil {
+		log.Fatalf("invalid CASE_RARITY_ODDS: %v", err)
+	}
+	inventoryService := inventory.NewService(pool, walletService, cfg.CatalogueDir, rarityOdds)
 	inventoryHandler := inventory.NewHandler(inventoryService)
 	eventsService := events.NewService(pool)
 	eventsHandler := events.NewHandler(eventsService)
-	lotteryService := lottery.NewService(pool, walletService)
+	lotteryService := lottery.NewService(pool, walletService, cfg.ActivityHalfLife)
 	lotteryHandler := lottery.NewHandler(lotteryService)
 	botClient, err := telegram.NewBotClient(cfg.TelegramBotToken, cfg.BaseURL)
 	if err != nil {
 		log.Fatalf("telegram bot startup failed: %v", err)
 	}
-	streamService := stream.NewService(pool, lotteryService, inventoryService, botClient, cfg.BaseURL, cfg.TelegramBotUsername)
+	steamClient := steam.NewClient(cfg.SteamWebAPIKey, cfg.SteamStub, cfg.SteamStubLevel, int64(cfg.SteamStubCS2Hours)*60)
+	streamService := stream.NewService(pool, lotteryService, inventoryService, botClient, cfg.BaseURL, cfg.TelegramBotUsername, cfg.StreamIdleTimeout, cfg.PresenceTimeout, steamClient)
+	botClient.SetChatMessageHandler(streamService.RecordChatMessage)
+	botClient.SetClaimHandler(streamService.ClaimByTelegram)
 	streamHandler := stream.NewHandler(streamService)
+	go streamService.RunScheduler(ctx, 30*time.Second)
 	authHandler := auth.NewHandler(authService, streamService)
 	casesService := cases.NewService(pool, walletService, lotteryService, inventoryService)
 	casesHandler := cases.NewHandler(casesService)
-	gsiHandler := gsi.NewHandler(eventsService, lotteryService, streamService, pool)
+	raffleService := raffles.NewService(pool, walletService, lotteryService, inventoryService)
+	raffleHandler := raffles.NewHandler(raffleService)
+	go raffleService.RunScheduler(ctx, 30*time.Second)
+	predictionService := predictions.NewService(pool, walletService)
+	predictionHandler := predictions.NewHandler(predictionService)
+	go predictionService.RunScheduler(ctx, 15*time.Second)
+	gsiHandler := gsi.NewHandler(eventsService, lotteryService, streamService, predictionService, pool)
 	telegramHandler := telegram.NewHandler(authService, cfg.TelegramBotToken)
 
 	r := chi.NewRouter()
@@ -76,7 +94,14 @@ func main() {
 		api.Get("/events", eventsHandler.ListRecent)
 		api.Post("/gsi", gsiHandler.Ingest)
 		api.Get("/lottery/rounds", lotteryHandler.ListRounds)
+		api.Get("/lottery/activity-types", lotteryHandler.ListActivityTypes)
+		api.Get("/lottery/rounds/{roundID}/verify", lotteryHandler.VerifyRound)
+		api.Get("/lottery/seeds/current", lotteryHandler.CurrentGlobalSeed)
+		api.Get("/lottery/sessions/{sessionID}/seed", lotteryHandler.SessionSeed)
 		api.Get("/cases", casesHandler.List)
+		api.Get("/catalogue/odds", inventoryHandler.ListCaseOdds)
+		api.Get("/store/cases", inventoryHandler.ListStore)
+		api.Get("/catalogue/cases/{caseID}/odds", inventoryHandler.GetCaseOdds)
 		api.Get("/streams/events/presets", streamHandler.ListEventPresets)
 
 		api.Group(func(authed chi.Router) {
@@ -86,35 +111,94 @@ func main() {
 			authed.Post("/events", eventsHandler.Create)
 			authed.Get("/events/me", eventsHandler.ListMine)
 			authed.Post("/lottery/join", lotteryHandler.Join)
+			authed.Get("/lottery/activity/me", lotteryHandler.MyActivity)
+			authed.Get("/lottery/rounds/me", lotteryHandler.MyRounds)
+			authed.Get("/lottery/claims/me", streamHandler.MyClaims)
+			authed.Post("/lottery/claims/{roundID}", streamHandler.ClaimPrize)
+			authed.Get("/raffles", raffleHandler.List)
+			authed.Get("/raffles/{raffleID}", raffleHandler.Get)
+			authed.Post("/raffles/{raffleID}/tickets", raffleHandler.BuyTickets)
+			authed.Get("/predictions", predictionHandler.List)
+			authed.Get("/predictions/{predictionID}", predictionHandler.Get)
+			authed.Post("/predictions/{predictionID}/stake", predictionHandler.Stake)
 			authed.Get("/wallet/me", walletHandler.GetMyWallet)
 			authed.Post("/wallet/topup", walletHandler.TopUp)
 			authed.Get("/wallet/transactions", walletHandler.ListMyTransactions)
 			authed.Get("/inventory/me", inventoryHandler.ListMine)
 			authed.Post("/inventory/open/{itemID}", inventoryHandler.OpenCase)
+			authed.Post("/inventory/open-bulk", inventoryHandler.OpenCases)
 			authed.Post("/inventory/sell/{itemID}", inventoryHandler.SellItem)
+			authed.Post("/store/cases/{caseID}/buy", inventoryHandler.BuyCases)
+			authed.Post("/store/cases/{caseID}/keys", inventoryHandler.BuyKeys)
 			authed.Post("/cases/{caseID}/contribute", casesHandler.Contribute)
 			authed.Get("/crowdfunding/invite/{inviteCode}", casesHandler.CampaignByInvite)
 			authed.Post("/streams/join/{inviteCode}", streamHandler.JoinInviteAuthenticated)
+			authed.Post("/streams/presence/{inviteCode}", streamHandler.Heartbeat)
+			authed.Get("/streams/delegations/me", streamHandler.ListDelegations)
+			authed.Post("/streams/{sessionID}/end", streamHandler.End)
+			authed.Get("/streams/{sessionID}/summary", streamHandler.Summary)
+			authed.Get("/streams/{sessionID}/summary/export", streamHandler.ExportSummary)
+			authed.Get("/streams/{sessionID}/participants", streamHandler.ListParticipants)
+			authed.Post("/streams/{sessionID}/participants/{userID}/kick", streamHandler.KickParticipant)
+			authed.Post("/streams/{sessionID}/participants/{userID}/ban", streamHandler.BanParticipant)
+			authed.Delete("/streams/{sessionID}/participants/{userID}/ban", streamHandler.UnbanParticipant)
+			authed.Get("/streams/{sessionID}/bans", streamHandler.ListBans)
+			authed.Post("/streams/{sessionID}/invites", streamHandler.CreateInviteLink)
+			authed.Get("/streams/{sessionID}/invites", streamHandler.ListInviteLinks)
+			authed.Delete("/streams/{sessionID}/invites/{linkID}", streamHandler.DeleteInviteLink)
+			authed.Get("/streams/{sessionID}/eligibility", streamHandler.GetEligibility)
+			authed.Put("/streams/{sessionID}/eligibility", streamHandler.SetEligibility)
+			authed.Put("/streams/{sessionID}/weighting", streamHandler.SetWeighting)
+			authed.Put("/streams/{sessionID}/win-limits", streamHandler.SetWinLimits)
+			authed.Put("/streams/{sessionID}/claim-window", streamHandler.SetClaimWindow)
+			authed.Post("/streams/{sessionID}/giveaways", streamHandler.AddGiveawayRule)
+			authed.Get("/streams/{sessionID}/giveaways", streamHandler.ListGiveawayRules)
+			authed.Put("/streams/{sessionID}/giveaways/{ruleID}", streamHandler.UpdateGiveawayRule)
+			authed.Delete("/streams/{sessionID}/giveaways/{ruleID}", streamHandler.DeleteGiveawayRule)
+			authed.Post("/streams/{sessionID}/draw", streamHandler.DrawNow)
 
 			authed.Group(func(streamer chi.Router) {
 				streamer.Use(authService.RequireRoles(auth.RoleStreamer, auth.RoleAdmin))
 				streamer.Post("/cases", casesHandler.Create)
 				streamer.Put("/cases/{caseID}", casesHandler.Update)
 				streamer.Delete("/cases/{caseID}", casesHandler.Delete)
+				streamer.Post("/raffles", raffleHandler.Create)
+				streamer.Post("/raffles/{raffleID}/cancel", raffleHandler.Cancel)
+				streamer.Post("/predictions", predictionHandler.Create)
+				streamer.Post("/predictions/{predictionID}/resolve", predictionHandler.Resolve)
+				streamer.Post("/predictions/{predictionID}/cancel", predictionHandler.Cancel)
 				streamer.Post("/streams/start", streamHandler.Start)
 				streamer.Get("/streams/me/active", streamHandler.ActiveMine)
-				streamer.Post("/streams/{sessionID}/end", streamHandler.End)
-				streamer.Get("/streams/{sessionID}/participants", streamHandler.ListParticipants)
-				streamer.Post("/streams/{sessionID}/giveaways", streamHandler.AddGiveawayRule)
-				streamer.Get("/streams/{sessionID}/giveaways", streamHandler.ListGiveawayRules)
-				streamer.Put("/streams/{sessionID}/giveaways/{ruleID}", streamHandler.UpdateGiveawayRule)
-				streamer.Delete("/streams/{sessionID}/giveaways/{ruleID}", streamHandler.DeleteGiveawayRule)
+				streamer.Post("/streams/schedule", streamHandler.Schedule)
+				streamer.Get("/streams/scheduled", streamHandler.ListScheduled)
+				streamer.Delete("/streams/scheduled/{sessionID}", streamHandler.CancelScheduled)
+				streamer.Post("/streams/{sessionID}/giveaways/save-template", streamHandler.SaveSessionAsTemplate)
+				streamer.Get("/streams/delegates", streamHandler.ListDelegates)
+				streamer.Post("/streams/delegates", streamHandler.GrantDelegate)
+				streamer.Delete("/streams/delegates/{userID}", streamHandler.RevokeDelegate)
+				streamer.Get("/streams/audit", streamHandler.ListAuditLog)
+				streamer.Get("/streams/templates", streamHandler.ListRuleTemplates)
+				streamer.Post("/streams/templates", streamHandler.CreateRuleTemplate)
+				streamer.Get("/streams/templates/{templateID}", streamHandler.GetRuleTemplate)
+				streamer.Put("/streams/templates/{templateID}", streamHandler.UpdateRuleTemplate)
+				streamer.Delete("/streams/templates/{templateID}", streamHandler.DeleteRuleTemplate)
 				streamer.Post("/gsi/fake", gsiHandler.GenerateFake)
 			})
 
 			authed.Group(func(admin chi.Router) {
 				admin.Use(authService.RequireRoles(auth.RoleAdmin))
 				admin.Put("/admin/users/{userID}/role", authHandler.SetUserRole)
+				admin.Post("/admin/giveaways/reconcile", streamHandler.ReconcilePrizes)
+				admin.Get("/admin/lottery/global", lotteryHandler.GlobalSettings)
+				admin.Put("/admin/lottery/global", lotteryHandler.UpdateGlobalSettings)
+				admin.Post("/admin/lottery/global/fund", lotteryHandler.FundGlobalPool)
+				admin.Put("/admin/lottery/activity-types/{key}", lotteryHandler.UpdateActivityType)
+				admin.Get("/admin/catalogue/cases", inventoryHandler.ListCaseDefinitions)
+				admin.Post("/admin/catalogue/cases", inventoryHandler.CreateCaseDefinition)
+				admin.Get("/admin/catalogue/cases/{caseID}", inventoryHandler.GetCaseDefinition)
+				admin.Put("/admin/catalogue/cases/{caseID}", inventoryHandler.UpdateCaseDefinition)
+				admin.Delete("/admin/catalogue/cases/{caseID}", inventoryHandler.DeleteCaseDefinition)
+				admin.Post("/admin/catalogue/import", inventoryHandler.ImportCatalogue)
 			})
 		})
 	})
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/fairness"
+)
+
+func main() {
+	input := io.Reader(os.Stdin)
+	if len(os.Args) > 1 {
+		file, err := os.Open(os.Args[1])
+		if err != nil {
+			log.Fatalf("open proof: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	raw, err := io.ReadAll(input)
+	if err != nil {
+		log.Fatalf("read proof: %v", err)
+	}
+
+	var wrapped struct {
+		Verification *fairness.Proof `json:"verification"`
+	}
+	var proof fairness.Proof
+	if err := json.Unmarshal(raw, &wrapped); err == nil && wrapped.Verification != nil {
+		proof = *wrapped.Verification
+	} else if err := json.Unmarshal(raw, &proof); err != nil {
+		log.Fatalf("decode proof: %v", err)
+	}
+
+	if err := fairness.Verify(proof); err != nil {
+		fmt.Printf("round %d: NOT verified: %v\n", proof.RoundID, err)
+		os.Exit(1)
+	}
+	fmt.Printf("round %d: verified, winner user %d\n", proof.RoundID, proof.WinnerUserID)
+}
@@ -0,0 +1,126 @@
+package fairness
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Candidate struct {
+	UserID int64 `json:"user_id"`
+	Weight int64 `json:"weight"`
+}
+
+type Proof struct {
+	ServerSeed       string      `json:"server_seed"`
+	Commitment       string      `json:"commitment"`
+	RoundID          int64       `json:"round_id"`
+	EventID          *int64      `json:"event_id,omitempty"`
+	ParticipantsHash string      `json:"participants_hash"`
+	Candidates       []Candidate `json:"candidates"`
+	WinnerUserID     int64       `json:"winner_user_id"`
+}
+
+func NewServerSeed() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func Commit(serverSeed string) string {
+	sum := sha256.Sum256([]byte(serverSeed))
+	return hex.EncodeToString(sum[:])
+}
+
+func Canonical(candidates []Candidate) []Candidate {
+	sorted := make([]Candidate, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UserID < sorted[j].UserID })
+	return sorted
+}
+
+func HashParticipants(candidates []Candidate) string {
+	parts := make([]string, 0, len(candidates))
+	for _, c := range Canonical(candidates) {
+		parts = append(parts, fmt.Sprintf("%d:%d", c.UserID, c.Weight))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+func PublicInput(roundID int64, eventID *int64, participantsHash string) string {
+	event := "none"
+	if eventID != nil {
+		event = strconv.FormatInt(*eventID, 10)
+	}
+	return fmt.Sprintf("round=%d;event=%s;participants=%s", roundID, event, participantsHash)
+}
+
+func Roll(serverSeed, publicInput string, totalWeight int64) (int64, error) {
+	if totalWeight <= 0 {
+		return 0, errors.New("invalid total weight")
+	}
+	limit := math.MaxUint64 - math.MaxUint64%uint64(totalWeight)
+	for nonce := 0; ; nonce++ {
+		mac := hmac.New(sha256.New, []byte(serverSeed))
+		fmt.Fprintf(mac, "%s;nonce=%d", publicInput, nonce)
+		v := binary.BigEndian.Uint64(mac.Sum(nil)[:8])
+		if v < limit {
+			return int64(v % uint64(totalWeight)), nil
+		}
+	}
+}
+
+func Draw(serverSeed, publicInput string, candidates []Candidate) (int64, int64, error) {
+	ordered := Canonical(candidates)
+	total := int64(0)
+	for _, c := range ordered {
+		if c.Weight < 0 {
+			return 0, 0, errors.New("negative candidate weight")
+		}
+		total += c.Weight
+	}
+	roll, err := Roll(serverSeed, publicInput, total)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	running := int64(0)
+	for _, c := range ordered {
+		running += c.Weight
+		if roll < running {
+			return c.UserID, roll, nil
+		}
+	}
+	return ordered[len(ordered)-1].UserID, roll, nil
+}
+
+func Verify(p Proof) error {
+	if p.ServerSeed == "" {
+		return errors.New("server seed has not been revealed")
+	}
+	if Commit(p.ServerSeed) != p.Commitment {
+		return errors.New("server seed does not match commitment")
+	}
+	if HashParticipants(p.Candidates) != p.ParticipantsHash {
+		return errors.New("participant list does not match participants hash")
+	}
+	winner, _, err := Draw(p.ServerSeed, PublicInput(p.RoundID, p.EventID, p.ParticipantsHash), p.Candidates)
+	if err != nil {
+		return err
+	}
+	if winner != p.WinnerUserID {
+		return fmt.Errorf("recomputed winner %d does not match recorded winner %d", winner, p.WinnerUserID)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package fairness
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSeed = "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0"
+
+var testCandidates = []Candidate{
+	{UserID: 7, Weight: 1},
+	{UserID: 3, Weight: 5},
+	{UserID: 11, Weight: 2},
+}
+
+const testParticipantsHash = "57c33c6377b619bc078462fd715d0ed92e9172091559a840156203dbc6c8709e"
+
+func TestCommit(t *testing.T) {
+	if got, want := Commit(testSeed), "331ab04caa328927f706627b812f4139f9ec42a6d61f17e468a70c41a48d8f67"; got != want {
+		t.Fatalf("Commit() = %s, want %s", got, want)
+	}
+}
+
+func TestHashParticipantsIgnoresOrder(t *testing.T) {
+	reversed := []Candidate{testCandidates[2], testCandidates[1], testCandidates[0]}
+	if got := HashParticipants(reversed); got != testParticipantsHash {
+		t.Fatalf("HashParticipants() = %s, want %s", got, testParticipantsHash)
+	}
+}
+
+func TestRoll(t *testing.T) {
+	input := "round=1;event=none;participants=abc"
+	tests := []struct {
+		total   int64
+		want    int64
+		wantErr bool
+	}{
+		{total: 1, want: 0},
+		{total: 2, want: 1},
+		{total: 10, want: 7},
+		{total: 1000, want: 237},
+		{total: 0, wantErr: true},
+		{total: -5, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Roll(testSeed, input, tt.total)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Roll(total=%d) expected error", tt.total)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Roll(total=%d) error: %v", tt.total, err)
+		}
+		if got != tt.want {
+			t.Errorf("Roll(total=%d) = %d, want %d", tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	event := int64(9)
+	tests := []struct {
+		name       string
+		input      string
+		candidates []Candidate
+		wantWinner int64
+		wantRoll   int64
+		wantErr    bool
+	}{
+		{name: "first slot", input: PublicInput(1, nil, testParticipantsHash), candidates: testCandidates, wantWinner: 3, wantRoll: 0},
+		{name: "last slot with event", input: PublicInput(2, &event, testParticipantsHash), candidates: testCandidates, wantWinner: 11, wantRoll: 6},
+		{name: "middle slot", input: PublicInput(3, nil, testParticipantsHash), candidates: testCandidates, wantWinner: 7, wantRoll: 5},
+		{name: "single candidate", input: PublicInput(1, nil, ""), candidates: []Candidate{{UserID: 42, Weight: 3}}, wantWinner: 42, wantRoll: 1},
+		{name: "negative weight", input: PublicInput(1, nil, ""), candidates: []Candidate{{UserID: 1, Weight: -1}, {UserID: 2, Weight: 3}}, wantErr: true},
+		{name: "no weight", input: PublicInput(1, nil, ""), candidates: []Candidate{{UserID: 1, Weight: 0}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			winner, roll, err := Draw(testSeed, tt.input, tt.candidates)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Draw() error: %v", err)
+			}
+			if winner != tt.wantWinner {
+				t.Errorf("winner = %d, want %d", winner, tt.wantWinner)
+			}
+			if roll != tt.wantRoll {
+				t.Errorf("roll = %d, want %d", roll, tt.wantRoll)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	event := int64(9)
+	valid := Proof{
+		ServerSeed:       testSeed,
+		Commitment:       Commit(testSeed),
+		RoundID:          2,
+		EventID:          &event,
+		ParticipantsHash: testParticipantsHash,
+		Candidates:       testCandidates,
+		WinnerUserID:     11,
+	}
+	tests := []struct {
+		name    string
+		mutate  func(p *Proof)
+		wantErr string
+	}{
+		{name: "valid", mutate: func(p *Proof) {}},
+		{name: "seed not revealed", mutate: func(p *Proof) { p.ServerSeed = "" }, wantErr: "not been revealed"},
+		{name: "wrong commitment", mutate: func(p *Proof) { p.Commitment = Commit("other") }, wantErr: "does not match commitment"},
+		{name: "tampered candidates", mutate: func(p *Proof) { p.Candidates = testCandidates[:2] }, wantErr: "participants hash"},
+		{name: "wrong winner", mutate: func(p *Proof) { p.WinnerUserID = 3 }, wantErr: "does not match recorded winner"},
+		{name: "wrong event", mutate: func(p *Proof) { p.EventID = nil }, wantErr: "does not match recorded winner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.mutate(&p)
+			err := Verify(p)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
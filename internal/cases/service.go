@@ -256,6 +256,7 @@ WHERE id = $3
 	if err := tx.Commit(ctx); err != nil {
 		return Case{}, nil, nil, err
 	}
+	_ = s.lottery.RecordActivity(ctx, userID, c.StreamSessionID, lottery.ActivityContribution)
 
 	var round *lottery.Round
 	var rewardItem *inventory.Item
@@ -307,6 +308,7 @@ SELECT c.id, c.streamer_id, c.stream_session_id, c.title, c.description, c.rewar
 FROM cases c
 JOIN stream_sessions ss ON ss.id = c.stream_session_id
 WHERE ss.invite_code = $1
+   OR ss.id = (SELECT stream_session_id FROM stream_invite_links WHERE code = $1)
 ORDER BY c.created_at DESC
 LIMIT 1
 `, inviteCode).Scan(
@@ -1,6 +1,10 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
 	Port                string
@@ -10,6 +14,15 @@ type Config struct {
 	FrontendPath        string
 	TelegramBotToken    string
 	TelegramBotUsername string
+	StreamIdleTimeout   time.Duration
+	PresenceTimeout     time.Duration
+	ActivityHalfLife    time.Duration
+	CatalogueDir        string
+	CaseRarityOdds      string
+	SteamWebAPIKey      string
+	SteamStub           bool
+	SteamStubLevel      int
+	SteamStubCS2Hours   int
 }
 
 func Load() Config {
@@ -21,6 +34,15 @@ func Load() Config {
 		FrontendPath:        getEnv("FRONTEND_PATH", "./web"),
 		TelegramBotToken:    getEnv("TELEGRAM_BOT_TOKEN", ""),
 		TelegramBotUsername: getEnv("TELEGRAM_BOT_USERNAME", ""),
+		StreamIdleTimeout:   time.Duration(getEnvInt("STREAM_IDLE_TIMEOUT_MINUTES", 60)) * time.Minute,
+		PresenceTimeout:     time.Duration(getEnvInt("PRESENCE_TIMEOUT_SECONDS", 120)) * time.Second,
+		ActivityHalfLife:    time.Duration(getEnvInt("ACTIVITY_HALF_LIFE_HOURS", 24)) * time.Hour,
+		CatalogueDir:        getEnv("CATALOGUE_DIR", "./data"),
+		CaseRarityOdds:      getEnv("CASE_RARITY_ODDS", ""),
+		SteamWebAPIKey:      getEnv("STEAM_WEB_API_KEY", ""),
+		SteamStub:           getEnv("STEAM_STUB", "") == "true",
+		SteamStubLevel:      getEnvInt("STEAM_STUB_LEVEL", 10),
+		SteamStubCS2Hours:   getEnvInt("STEAM_STUB_CS2_HOURS", 100),
 	}
 }
 
@@ -30,3 +52,11 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -146,6 +146,38 @@ CREATE TABLE IF NOT EXISTS viewer_activity (
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+CREATE TABLE IF NOT EXISTS activity_types (
+    key TEXT PRIMARY KEY,
+    label TEXT NOT NULL,
+    points BIGINT NOT NULL DEFAULT 1 CHECK (points >= 0),
+    cooldown_seconds INT NOT NULL DEFAULT 0 CHECK (cooldown_seconds >= 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO activity_types (key, label, points, cooldown_seconds) VALUES
+    ('join', 'Joined a stream', 2, 86400),
+    ('presence', 'Watching (presence heartbeat)', 1, 300),
+    ('contribution', 'Contributed to a case', 3, 0),
+    ('chat_message', 'Telegram chat message', 1, 60),
+    ('gsi_packet', 'Game state update', 1, 0),
+    ('lottery_join', 'Joined the lottery', 1, 3600)
+ON CONFLICT (key) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS viewer_activity_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stream_session_id BIGINT,
+    activity_type TEXT NOT NULL,
+    points BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_viewer_activity_events_user ON viewer_activity_events (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_viewer_activity_events_session ON viewer_activity_events (stream_session_id, user_id);
+INSERT INTO viewer_activity_events (user_id, activity_type, points, created_at)
+SELECT user_id, 'legacy', score, updated_at
+FROM viewer_activity
+WHERE score > 0 AND NOT EXISTS (SELECT 1 FROM viewer_activity_events);
+
 CREATE TABLE IF NOT EXISTS lottery_rounds (
     id BIGSERIAL PRIMARY KEY,
     trigger_event_id BIGINT REFERENCES events(id) ON DELETE SET NULL,
@@ -161,6 +193,19 @@ CREATE TABLE IF NOT EXISTS lottery_rounds (
 CREATE INDEX IF NOT EXISTS idx_lottery_rounds_created_at ON lottery_rounds (created_at DESC);
 ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS stream_session_id BIGINT;
 
+CREATE TABLE IF NOT EXISTS global_lottery_settings (
+    id INT PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    trigger_types TEXT[] NOT NULL DEFAULT ARRAY['ace', 'headshot', 'bomb_plant'],
+    prize_cents BIGINT NOT NULL DEFAULT 100,
+    funding_source TEXT NOT NULL DEFAULT 'pool' CHECK (funding_source IN ('pool', 'platform')),
+    pool_cents BIGINT NOT NULL DEFAULT 0 CHECK (pool_cents >= 0),
+    scope TEXT NOT NULL DEFAULT 'stream' CHECK (scope IN ('stream', 'platform')),
+    activity_window_hours INT NOT NULL DEFAULT 24,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+INSERT INTO global_lottery_settings (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
+
 CREATE TABLE IF NOT EXISTS gsi_packets (
     packet_hash TEXT PRIMARY KEY,
     user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
@@ -180,6 +225,40 @@ CREATE TABLE IF NOT EXISTS stream_sessions (
 );
 
 CREATE INDEX IF NOT EXISTS idx_stream_sessions_streamer_id ON stream_sessions (streamer_id, created_at DESC);
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS recurrence TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS reminder_minutes INT NOT NULL DEFAULT 15;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMPTZ;
+UPDATE stream_sessions SET started_at = created_at WHERE started_at IS NULL AND status <> 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_stream_sessions_scheduled ON stream_sessions (status, scheduled_at);
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS end_reason TEXT;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMPTZ;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS weighting TEXT NOT NULL DEFAULT 'combined';
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS weight_cap BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS max_wins_per_hour INT NOT NULL DEFAULT 0;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS max_wins_per_session INT NOT NULL DEFAULT 0;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS max_win_value_cents BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS win_limit_mode TEXT NOT NULL DEFAULT 'exclude';
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS claim_window_minutes INT NOT NULL DEFAULT 0;
+UPDATE stream_sessions ss
+SET status = 'ended', ended_at = NOW(), end_reason = 'superseded'
+WHERE ss.status = 'active'
+  AND EXISTS (
+      SELECT 1 FROM stream_sessions newer
+      WHERE newer.streamer_id = ss.streamer_id
+        AND newer.status = 'active'
+        AND (newer.created_at, newer.id) > (ss.created_at, ss.id)
+  );
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stream_sessions_one_active ON stream_sessions (streamer_id) WHERE status = 'active';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'stream_sessions_recurrence_check') THEN
+        ALTER TABLE stream_sessions
+        ADD CONSTRAINT stream_sessions_recurrence_check CHECK (recurrence IN ('none', 'daily', 'weekly'));
+    END IF;
+END$$;
 
 DO $$
 BEGIN
@@ -197,6 +276,68 @@ CREATE TABLE IF NOT EXISTS stream_participants (
 );
 
 CREATE INDEX IF NOT EXISTS idx_stream_participants_user_id ON stream_participants (user_id, joined_at DESC);
+ALTER TABLE stream_participants ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE TABLE IF NOT EXISTS stream_invite_links (
+    id BIGSERIAL PRIMARY KEY,
+    stream_session_id BIGINT NOT NULL REFERENCES stream_sessions(id) ON DELETE CASCADE,
+    code TEXT NOT NULL UNIQUE,
+    label TEXT NOT NULL,
+    max_uses INT NOT NULL DEFAULT 0,
+    uses INT NOT NULL DEFAULT 0,
+    clicks INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stream_invite_links_session ON stream_invite_links (stream_session_id);
+ALTER TABLE stream_participants ADD COLUMN IF NOT EXISTS invite_link_id BIGINT REFERENCES stream_invite_links(id) ON DELETE SET NULL;
+ALTER TABLE stream_invite_links ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS stream_delegates (
+    streamer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('moderator', 'co_streamer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (streamer_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stream_delegates_user_id ON stream_delegates (user_id);
+
+CREATE TABLE IF NOT EXISTS stream_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    streamer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stream_session_id BIGINT REFERENCES stream_sessions(id) ON DELETE SET NULL,
+    actor_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_role TEXT NOT NULL,
+    action TEXT NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stream_audit_log_streamer ON stream_audit_log (streamer_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS stream_bans (
+    id BIGSERIAL PRIMARY KEY,
+    streamer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stream_session_id BIGINT REFERENCES stream_sessions(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stream_bans_scope ON stream_bans (streamer_id, user_id, (COALESCE(stream_session_id, 0)));
+
+CREATE TABLE IF NOT EXISTS stream_eligibility_policies (
+    stream_session_id BIGINT PRIMARY KEY REFERENCES stream_sessions(id) ON DELETE CASCADE,
+    require_steam BOOLEAN NOT NULL DEFAULT FALSE,
+    min_account_age_days INT NOT NULL DEFAULT 0,
+    min_steam_level INT NOT NULL DEFAULT 0,
+    min_cs2_hours INT NOT NULL DEFAULT 0,
+    require_telegram_member BOOLEAN NOT NULL DEFAULT FALSE,
+    max_participants INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
 
 CREATE TABLE IF NOT EXISTS giveaway_rules (
     id BIGSERIAL PRIMARY KEY,
@@ -211,6 +352,8 @@ CREATE TABLE IF NOT EXISTS giveaway_rules (
 
 CREATE INDEX IF NOT EXISTS idx_giveaway_rules_session_trigger ON giveaway_rules (stream_session_id, trigger_type, enabled);
 ALTER TABLE giveaway_rules ADD COLUMN IF NOT EXISTS prize_type TEXT NOT NULL DEFAULT 'skin';
+ALTER TABLE giveaway_rules ADD COLUMN IF NOT EXISTS weighting TEXT;
+ALTER TABLE giveaway_rules ADD COLUMN IF NOT EXISTS weight_cap BIGINT NOT NULL DEFAULT 0;
 
 DO $$
 BEGIN
@@ -220,6 +363,31 @@ BEGIN
     END IF;
 END$$;
 
+CREATE TABLE IF NOT EXISTS giveaway_rule_templates (
+    id BIGSERIAL PRIMARY KEY,
+    streamer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (streamer_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS giveaway_rule_template_items (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT NOT NULL REFERENCES giveaway_rule_templates(id) ON DELETE CASCADE,
+    position INT NOT NULL DEFAULT 0,
+    trigger_type TEXT NOT NULL,
+    prize_type TEXT NOT NULL DEFAULT 'skin' CHECK (prize_type IN ('skin', 'case')),
+    prize_name TEXT NOT NULL,
+    prize_cents BIGINT NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_giveaway_rule_template_items_template ON giveaway_rule_template_items (template_id, position);
+ALTER TABLE giveaway_rule_template_items ADD COLUMN IF NOT EXISTS weighting TEXT;
+ALTER TABLE giveaway_rule_template_items ADD COLUMN IF NOT EXISTS weight_cap BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE stream_sessions ADD COLUMN IF NOT EXISTS template_id BIGINT REFERENCES giveaway_rule_templates(id) ON DELETE SET NULL;
+
 CREATE TABLE IF NOT EXISTS inventory_items (
     id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -240,19 +408,174 @@ CREATE INDEX IF NOT EXISTS idx_inventory_user_created ON inventory_items (user_i
 CREATE INDEX IF NOT EXISTS idx_inventory_parent_item_id ON inventory_items (parent_item_id);
 ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS price_cents BIGINT NOT NULL DEFAULT 0;
 ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS sold_at TIMESTAMPTZ;
+ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS wear TEXT;
+ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS float_value DOUBLE PRECISION;
+ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS stattrak BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS paint_seed INT;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS prize_item_id BIGINT REFERENCES inventory_items(id) ON DELETE SET NULL;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS prize_delivered_at TIMESTAMPTZ;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS prize_delivery_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS prize_delivery_error TEXT;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS claim_status TEXT;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS claim_expires_at TIMESTAMPTZ;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS redraw_of_round_id BIGINT REFERENCES lottery_rounds(id) ON DELETE SET NULL;
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS redrawn_round_id BIGINT REFERENCES lottery_rounds(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_lottery_rounds_pending_claims ON lottery_rounds (claim_expires_at) WHERE claim_status = 'pending';
+
+CREATE TABLE IF NOT EXISTS draw_seeds (
+    id BIGSERIAL PRIMARY KEY,
+    stream_session_id BIGINT UNIQUE REFERENCES stream_sessions(id) ON DELETE CASCADE,
+    server_seed TEXT NOT NULL,
+    commitment TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revealed_at TIMESTAMPTZ
+);
+
+ALTER TABLE lottery_rounds ADD COLUMN IF NOT EXISTS seed_id BIGINT REFERENCES draw_seeds(id) ON DELETE SET NULL;
 
 DO $$
 BEGIN
     IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'inventory_status_check') THEN
         ALTER TABLE inventory_items DROP CONSTRAINT inventory_status_check;
     END IF;
-    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'inventory_item_type_check') THEN
-        ALTER TABLE inventory_items
-        ADD CONSTRAINT inventory_item_type_check CHECK (item_type IN ('skin', 'case'));
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'inventory_item_type_check') THEN
+        ALTER TABLE inventory_items DROP CONSTRAINT inventory_item_type_check;
     END IF;
     ALTER TABLE inventory_items
-    ADD CONSTRAINT inventory_status_check CHECK (status IN ('available', 'unopened', 'opened', 'sold'));
+    ADD CONSTRAINT inventory_item_type_check CHECK (item_type IN ('skin', 'case', 'key'));
+    ALTER TABLE inventory_items
+    ADD CONSTRAINT inventory_status_check CHECK (status IN ('available', 'unopened', 'opened', 'sold', 'used'));
 END$$;
+
+CREATE TABLE IF NOT EXISTS raffles (
+    id BIGSERIAL PRIMARY KEY,
+    streamer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stream_session_id BIGINT REFERENCES stream_sessions(id) ON DELETE SET NULL,
+    title TEXT NOT NULL,
+    prize_type TEXT NOT NULL CHECK (prize_type IN ('skin', 'case')),
+    prize_name TEXT NOT NULL,
+    prize_cents BIGINT NOT NULL DEFAULT 0,
+    ticket_price_cents BIGINT NOT NULL CHECK (ticket_price_cents > 0),
+    max_tickets_per_user INT NOT NULL DEFAULT 0,
+    ends_at TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'drawn', 'no_entries', 'cancelled')),
+    lottery_round_id BIGINT REFERENCES lottery_rounds(id) ON DELETE SET NULL,
+    winner_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    cancelled_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_raffles_due ON raffles (status, ends_at);
+ALTER TABLE raffles ADD COLUMN IF NOT EXISTS prize_escrow_cents BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS raffle_tickets (
+    id BIGSERIAL PRIMARY KEY,
+    raffle_id BIGINT NOT NULL REFERENCES raffles(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    amount_cents BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    refunded_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_raffle_tickets_raffle_user ON raffle_tickets (raffle_id, user_id);
+
+CREATE TABLE IF NOT EXISTS predictions (
+    id BIGSERIAL PRIMARY KEY,
+    streamer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stream_session_id BIGINT REFERENCES stream_sessions(id) ON DELETE SET NULL,
+    question TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'refunded', 'cancelled')),
+    locks_at TIMESTAMPTZ NOT NULL,
+    resolves_at TIMESTAMPTZ NOT NULL,
+    winning_outcome_id BIGINT,
+    resolution_source TEXT,
+    resolution_event_id BIGINT REFERENCES events(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_predictions_open ON predictions (streamer_id, status, resolves_at);
+
+CREATE TABLE IF NOT EXISTS prediction_outcomes (
+    id BIGSERIAL PRIMARY KEY,
+    prediction_id BIGINT NOT NULL REFERENCES predictions(id) ON DELETE CASCADE,
+    position INT NOT NULL DEFAULT 0,
+    label TEXT NOT NULL,
+    event_type TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_prediction_outcomes_prediction ON prediction_outcomes (prediction_id);
+
+CREATE TABLE IF NOT EXISTS prediction_stakes (
+    id BIGSERIAL PRIMARY KEY,
+    prediction_id BIGINT NOT NULL REFERENCES predictions(id) ON DELETE CASCADE,
+    outcome_id BIGINT NOT NULL REFERENCES prediction_outcomes(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+    payout_cents BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    settled_at TIMESTAMPTZ,
+    UNIQUE (prediction_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_prediction_stakes_outcome ON prediction_stakes (outcome_id);
+
+CREATE TABLE IF NOT EXISTS case_definitions (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    image_url TEXT,
+    price_cents BIGINT NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    source TEXT NOT NULL DEFAULT 'manual',
+    external_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_case_definitions_default ON case_definitions (is_default) WHERE is_default;
+
+CREATE TABLE IF NOT EXISTS case_definition_drops (
+    id BIGSERIAL PRIMARY KEY,
+    case_id BIGINT NOT NULL REFERENCES case_definitions(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    rarity TEXT NOT NULL,
+    weight BIGINT NOT NULL CHECK (weight > 0),
+    image_url TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_case_definition_drops_case ON case_definition_drops (case_id);
+ALTER TABLE case_definitions ADD COLUMN IF NOT EXISTS requires_key BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE case_definitions ADD COLUMN IF NOT EXISTS key_price_cents BIGINT NOT NULL DEFAULT 0 CHECK (key_price_cents >= 0);
+ALTER TABLE case_definitions ADD COLUMN IF NOT EXISTS for_sale BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE case_definition_drops ADD COLUMN IF NOT EXISTS min_float DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE case_definition_drops ADD COLUMN IF NOT EXISTS max_float DOUBLE PRECISION NOT NULL DEFAULT 1;
+ALTER TABLE case_definition_drops ADD COLUMN IF NOT EXISTS stattrak BOOLEAN NOT NULL DEFAULT TRUE;
+
+INSERT INTO case_definitions (name, price_cents, is_default, source)
+SELECT v.name, v.price_cents, v.is_default, 'seed'
+FROM (VALUES ('Standard Drop Pool', 120, TRUE), ('Knife Fever Case', 1250, FALSE)) AS v(name, price_cents, is_default)
+WHERE NOT EXISTS (SELECT 1 FROM case_definitions);
+
+INSERT INTO case_definition_drops (case_id, name, rarity, weight)
+SELECT d.id, v.name, v.rarity, v.weight
+FROM case_definitions d
+JOIN (VALUES
+    ('Standard Drop Pool', 'UMP-45 | Briefing', 'mil-spec', 18),
+    ('Standard Drop Pool', 'AK-47 | Slate', 'restricted', 12),
+    ('Standard Drop Pool', 'M4A1-S | Cyrex', 'classified', 7),
+    ('Standard Drop Pool', 'AWP | Wildfire', 'covert', 3),
+    ('Knife Fever Case', 'UMP-45 | Briefing', 'mil-spec', 18),
+    ('Knife Fever Case', 'AK-47 | Slate', 'restricted', 18),
+    ('Knife Fever Case', 'M4A1-S | Cyrex', 'classified', 14),
+    ('Knife Fever Case', 'AWP | Wildfire', 'covert', 11),
+    ('Knife Fever Case', 'Karambit | Doppler', 'gold', 2),
+    ('Knife Fever Case', 'M9 Bayonet | Fade', 'gold', 2),
+    ('Knife Fever Case', 'Butterfly Knife | Slaughter', 'gold', 1)
+) AS v(case_name, name, rarity, weight) ON v.case_name = d.name
+WHERE d.source = 'seed'
+  AND NOT EXISTS (SELECT 1 FROM case_definition_drops x WHERE x.case_id = d.id);
+
+DELETE FROM case_definition_drops x
+USING case_definitions d
+WHERE x.case_id = d.id AND d.source = 'seed'
+  AND (x.name, x.rarity) IN (('P250 | Sand Dune', 'consumer'), ('MP9 | Storm', 'industrial'));
 `)
 	return err
 }
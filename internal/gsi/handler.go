@@ -14,19 +14,21 @@ import (
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/events"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/httpx"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/predictions"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/stream"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Handler struct {
-	events  *events.Service
-	lottery *lottery.Service
-	stream  *stream.Service
-	db      *pgxpool.Pool
+	events      *events.Service
+	lottery     *lottery.Service
+	stream      *stream.Service
+	predictions *predictions.Service
+	db          *pgxpool.Pool
 }
 
-func NewHandler(events *events.Service, lottery *lottery.Service, stream *stream.Service, db *pgxpool.Pool) *Handler {
-	return &Handler{events: events, lottery: lottery, stream: stream, db: db}
+func NewHandler(events *events.Service, lottery *lottery.Service, stream *stream.Service, predictions *predictions.Service, db *pgxpool.Pool) *Handler {
+	return &Handler{events: events, lottery: lottery, stream: stream, predictions: predictions, db: db}
 }
 
 func (h *Handler) Ingest(w http.ResponseWriter, r *http.Request) {
@@ -44,7 +46,7 @@ func (h *Handler) Ingest(w http.ResponseWriter, r *http.Request) {
 	var userID *int64
 	if user, ok := auth.UserFromContext(r.Context()); ok {
 		userID = &user.ID
-		_ = h.lottery.RecordActivity(r.Context(), user.ID, 1)
+		_ = h.lottery.RecordActivity(r.Context(), user.ID, nil, lottery.ActivityGSIPacket)
 	}
 
 	stored, triggeredRounds, packetHash, deduplicated, err := h.processPayload(r.Context(), payload, userID)
@@ -128,6 +130,10 @@ func (h *Handler) processPayload(ctx context.Context, payload map[string]interfa
 		return nil, nil, packetHash, true, nil
 	}
 
+	if userID != nil && h.stream != nil {
+		_ = h.stream.TouchActivity(ctx, *userID)
+	}
+
 	stored := make([]events.Event, 0)
 	triggeredRounds := make([]lottery.Round, 0)
 	eventIDs := make([]int64, 0)
@@ -148,11 +154,9 @@ func (h *Handler) processPayload(ctx context.Context, payload map[string]interfa
 		stored = append(stored, event)
 		eventIDs = append(eventIDs, event.ID)
 
-		if ev.Type == "ace" || ev.Type == "headshot" || ev.Type == "bomb_plant" {
-			round, err := h.lottery.TriggerFromGameEvent(ctx, ev.Type, &event.ID, 100)
-			if err == nil && round != nil {
-				triggeredRounds = append(triggeredRounds, *round)
-			}
+		round, err := h.lottery.TriggerGlobal(ctx, ev.Type, &event.ID, userID)
+		if err == nil && round != nil {
+			triggeredRounds = append(triggeredRounds, *round)
 		}
 		if userID != nil && h.stream != nil {
 			streamRounds, err := h.stream.HandleGameEvent(ctx, *userID, ev.Type, &event.ID)
@@ -160,6 +164,9 @@ func (h *Handler) processPayload(ctx context.Context, payload map[string]interfa
 				triggeredRounds = append(triggeredRounds, streamRounds...)
 			}
 		}
+		if userID != nil && h.predictions != nil {
+			h.predictions.HandleGameEvent(ctx, *userID, ev.Type, &event.ID)
+		}
 	}
 
 	_ = h.attachEventIDs(ctx, packetHash, eventIDs)
@@ -0,0 +1,138 @@
+package inventory
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+type SkinAttributes struct {
+	Wear       string  `json:"wear"`
+	FloatValue float64 `json:"float_value"`
+	StatTrak   bool    `json:"stattrak"`
+	PaintSeed  int     `json:"paint_seed"`
+}
+
+type wearTier struct {
+	Name        string
+	MaxFloat    float64
+	PriceFactor float64
+}
+
+var wearTiers = []wearTier{
+	{Name: "Factory New", MaxFloat: 0.07, PriceFactor: 1.6},
+	{Name: "Minimal Wear", MaxFloat: 0.15, PriceFactor: 1.25},
+	{Name: "Field-Tested", MaxFloat: 0.38, PriceFactor: 1},
+	{Name: "Well-Worn", MaxFloat: 0.45, PriceFactor: 0.85},
+	{Name: "Battle-Scarred", MaxFloat: 1, PriceFactor: 0.75},
+}
+
+const (
+	statTrakChancePercent = 10
+	statTrakPriceFactor   = 1.8
+	maxPaintSeed          = 1000
+	floatResolution       = 1_000_000_000
+)
+
+func rollAttributes(drop weightedDrop) (SkinAttributes, error) {
+	minFloat, maxFloat := drop.MinFloat, drop.MaxFloat
+	if maxFloat <= minFloat || maxFloat > 1 || minFloat < 0 {
+		minFloat, maxFloat = 0, 1
+	}
+
+	r, err := rand.Int(rand.Reader, big.NewInt(floatResolution))
+	if err != nil {
+		return SkinAttributes{}, err
+	}
+	floatValue := minFloat + (maxFloat-minFloat)*float64(r.Int64())/floatResolution
+
+	statTrak := false
+	if drop.StatTrak {
+		roll, err := rand.Int(rand.Reader, big.NewInt(100))
+		if err != nil {
+			return SkinAttributes{}, err
+		}
+		statTrak = roll.Int64() < statTrakChancePercent
+	}
+
+	seed, err := rand.Int(rand.Reader, big.NewInt(maxPaintSeed+1))
+	if err != nil {
+		return SkinAttributes{}, err
+	}
+
+	return SkinAttributes{
+		Wear:       wearForFloat(floatValue).Name,
+		FloatValue: floatValue,
+		StatTrak:   statTrak,
+		PaintSeed:  int(seed.Int64()),
+	}, nil
+}
+
+func expectedPriceFactor(drop weightedDrop) float64 {
+	minFloat, maxFloat := drop.MinFloat, drop.MaxFloat
+	if maxFloat <= minFloat || maxFloat > 1 || minFloat < 0 {
+		minFloat, maxFloat = 0, 1
+	}
+
+	factor, lower := 0.0, 0.0
+	for _, tier := range wearTiers {
+		lo, hi := max(lower, minFloat), min(tier.MaxFloat, maxFloat)
+		if hi > lo {
+			factor += (hi - lo) * tier.PriceFactor
+		}
+		lower = tier.MaxFloat
+	}
+	factor /= maxFloat - minFloat
+	if drop.StatTrak {
+		factor *= 1 + float64(statTrakChancePercent)/100*(statTrakPriceFactor-1)
+	}
+	return factor
+}
+
+func wearForFloat(floatValue float64) wearTier {
+	for _, tier := range wearTiers {
+		if floatValue < tier.MaxFloat {
+			return tier
+		}
+	}
+	return wearTiers[len(wearTiers)-1]
+}
+
+func wearByName(name string) (wearTier, bool) {
+	for _, tier := range wearTiers {
+		if strings.EqualFold(tier.Name, strings.TrimSpace(name)) {
+			return tier, true
+		}
+	}
+	return wearTier{}, false
+}
+
+func (a SkinAttributes) priceFactor() float64 {
+	factor := 1.0
+	if tier, ok := wearByName(a.Wear); ok {
+		factor = tier.PriceFactor
+	}
+	if a.StatTrak {
+		factor *= statTrakPriceFactor
+	}
+	return factor
+}
+
+func marketHashName(name, rarity string, attrs *SkinAttributes) string {
+	name = strings.TrimSpace(name)
+	if attrs == nil {
+		return name
+	}
+
+	prefix := ""
+	if strings.EqualFold(rarity, "gold") && !strings.HasPrefix(name, "★") {
+		prefix = "★ "
+	}
+	if attrs.StatTrak {
+		prefix += "StatTrak™ "
+	}
+	if attrs.Wear == "" {
+		return prefix + name
+	}
+	return prefix + name + " (" + attrs.Wear + ")"
+}
@@ -0,0 +1,109 @@
+package inventory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+)
+
+const maxBulkOpen = 50
+
+type BulkOpenResult struct {
+	BatchID string `json:"batch_id"`
+	Opened  []Item `json:"opened_cases"`
+	Drops   []Item `json:"drops"`
+}
+
+func (s *Service) OpenCases(ctx context.Context, userID int64, itemIDs []int64, count int) (BulkOpenResult, error) {
+	ids := make([]int64, 0, len(itemIDs))
+	seen := make(map[int64]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 && count <= 0 {
+		return BulkOpenResult{}, errors.New("item_ids or count is required")
+	}
+	if len(ids) > maxBulkOpen || count > maxBulkOpen {
+		return BulkOpenResult{}, errors.New("you can open at most 50 cases at once")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return BulkOpenResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	query, args := `
+SELECT `+itemColumns+`
+FROM inventory_items
+WHERE user_id = $1 AND id = ANY($2)
+ORDER BY id
+FOR UPDATE
+`, []interface{}{userID, ids}
+	if len(ids) == 0 {
+		query, args = `
+SELECT `+itemColumns+`
+FROM inventory_items
+WHERE user_id = $1 AND item_type = 'case' AND status = 'unopened'
+ORDER BY created_at, id
+LIMIT $2
+FOR UPDATE
+`, []interface{}{userID, count}
+	}
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return BulkOpenResult{}, err
+	}
+	cases := make([]Item, 0)
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			rows.Close()
+			return BulkOpenResult{}, err
+		}
+		cases = append(cases, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return BulkOpenResult{}, err
+	}
+	if len(ids) > 0 && len(cases) != len(ids) {
+		return BulkOpenResult{}, errors.New("some items were not found")
+	}
+	if len(cases) == 0 {
+		return BulkOpenResult{}, errors.New("no unopened cases to open")
+	}
+
+	batchID, err := newBatchID()
+	if err != nil {
+		return BulkOpenResult{}, err
+	}
+	result := BulkOpenResult{BatchID: batchID, Opened: make([]Item, 0, len(cases)), Drops: make([]Item, 0, len(cases))}
+	for _, caseItem := range cases {
+		opened, skin, err := s.openCaseTx(ctx, tx, userID, caseItem, batchID)
+		if err != nil {
+			return BulkOpenResult{}, err
+		}
+		result.Opened = append(result.Opened, opened)
+		result.Drops = append(result.Drops, skin)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return BulkOpenResult{}, err
+	}
+	s.warmDropPrices(result.Drops)
+	return result, nil
+}
+
+func newBatchID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
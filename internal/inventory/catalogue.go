@@ -0,0 +1,534 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type CaseDefinition struct {
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	ImageURL      string     `json:"image_url,omitempty"`
+	PriceCents    int64      `json:"price_cents"`
+	Enabled       bool       `json:"enabled"`
+	IsDefault     bool       `json:"is_default"`
+	RequiresKey   bool       `json:"requires_key"`
+	KeyPriceCents int64      `json:"key_price_cents"`
+	ForSale       bool       `json:"for_sale"`
+	Source        string     `json:"source"`
+	ExternalID    string     `json:"external_id,omitempty"`
+	Drops         []CaseDrop `json:"drops"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type CaseDrop struct {
+	Name     string  `json:"name"`
+	Rarity   string  `json:"rarity"`
+	Weight   int64   `json:"weight"`
+	ImageURL string  `json:"image_url,omitempty"`
+	MinFloat float64 `json:"min_float"`
+	MaxFloat float64 `json:"max_float"`
+	StatTrak bool    `json:"stattrak"`
+}
+
+type ImportReport struct {
+	Cases   int `json:"cases"`
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Drops   int `json:"drops"`
+	Skipped int `json:"skipped"`
+}
+
+const caseDefinitionColumns = `id, name, COALESCE(image_url, ''), price_cents, enabled, is_default, requires_key, key_price_cents, for_sale, source, COALESCE(external_id, ''), created_at, updated_at`
+
+var importTierWeights = map[string]int64{
+	"consumer":   7992,
+	"industrial": 7992,
+	"mil-spec":   7992,
+	"restricted": 1598,
+	"classified": 320,
+	"covert":     64,
+	"gold":       26,
+}
+
+func (s *Service) ListCaseDefinitions(ctx context.Context, includeDisabled bool) ([]CaseDefinition, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT `+caseDefinitionColumns+`
+FROM case_definitions
+WHERE $1 OR enabled
+ORDER BY name
+`, includeDisabled)
+	if err != nil {
+		return nil, err
+	}
+	defs := make([]CaseDefinition, 0)
+	for rows.Next() {
+		def, err := scanCaseDefinition(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range defs {
+		drops, err := s.listCaseDrops(ctx, s.db, defs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		defs[i].Drops = drops
+	}
+	return defs, nil
+}
+
+func (s *Service) GetCaseDefinition(ctx context.Context, caseID int64) (CaseDefinition, error) {
+	def, err := scanCaseDefinition(s.db.QueryRow(ctx, `SELECT `+caseDefinitionColumns+` FROM case_definitions WHERE id = $1`, caseID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CaseDefinition{}, errors.New("case definition not found")
+	}
+	if err != nil {
+		return CaseDefinition{}, err
+	}
+	def.Drops, err = s.listCaseDrops(ctx, s.db, def.ID)
+	return def, err
+}
+
+func (s *Service) CreateCaseDefinition(ctx context.Context, def CaseDefinition) (CaseDefinition, error) {
+	if err := normalizeCaseDefinition(&def); err != nil {
+		return CaseDefinition{}, err
+	}
+	if err := s.checkStorePrice(def); err != nil {
+		return CaseDefinition{}, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return CaseDefinition{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if def.IsDefault {
+		if _, err := tx.Exec(ctx, `UPDATE case_definitions SET is_default = FALSE WHERE is_default`); err != nil {
+			return CaseDefinition{}, err
+		}
+	}
+	var id int64
+	err = tx.QueryRow(ctx, `
+INSERT INTO case_definitions (name, image_url, price_cents, enabled, is_default, requires_key, key_price_cents, for_sale, source)
+VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, 'manual')
+RETURNING id
+`, def.Name, def.ImageURL, def.PriceCents, def.Enabled, def.IsDefault, def.RequiresKey, def.KeyPriceCents, def.ForSale).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return CaseDefinition{}, errors.New("a case with this name already exists")
+		}
+		return CaseDefinition{}, err
+	}
+	if err := replaceCaseDrops(ctx, tx, id, def.Drops); err != nil {
+		return CaseDefinition{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return CaseDefinition{}, err
+	}
+	return s.GetCaseDefinition(ctx, id)
+}
+
+func (s *Service) UpdateCaseDefinition(ctx context.Context, caseID int64, def CaseDefinition) (CaseDefinition, error) {
+	if err := normalizeCaseDefinition(&def); err != nil {
+		return CaseDefinition{}, err
+	}
+	if err := s.checkStorePrice(def); err != nil {
+		return CaseDefinition{}, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return CaseDefinition{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if def.IsDefault {
+		if _, err := tx.Exec(ctx, `UPDATE case_definitions SET is_default = FALSE WHERE is_default AND id <> $1`, caseID); err != nil {
+			return CaseDefinition{}, err
+		}
+	}
+	result, err := tx.Exec(ctx, `
+UPDATE case_definitions
+SET name = $2, image_url = NULLIF($3, ''), price_cents = $4, enabled = $5, is_default = $6, requires_key = $7, key_price_cents = $8, for_sale = $9, updated_at = NOW()
+WHERE id = $1
+`, caseID, def.Name, def.ImageURL, def.PriceCents, def.Enabled, def.IsDefault, def.RequiresKey, def.KeyPriceCents, def.ForSale)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return CaseDefinition{}, errors.New("a case with this name already exists")
+		}
+		return CaseDefinition{}, err
+	}
+	if result.RowsAffected() == 0 {
+		return CaseDefinition{}, errors.New("case definition not found")
+	}
+	if err := replaceCaseDrops(ctx, tx, caseID, def.Drops); err != nil {
+		return CaseDefinition{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return CaseDefinition{}, err
+	}
+	return s.GetCaseDefinition(ctx, caseID)
+}
+
+func (s *Service) DeleteCaseDefinition(ctx context.Context, caseID int64) error {
+	result, err := s.db.Exec(ctx, `DELETE FROM case_definitions WHERE id = $1`, caseID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("case definition not found")
+	}
+	return nil
+}
+
+func (s *Service) ImportByMykel(ctx context.Context, cratesFile, skinsFile string) (ImportReport, error) {
+	var report ImportReport
+	if s.catalogueDir == "" {
+		return report, errors.New("catalogue directory is not configured")
+	}
+	if cratesFile == "" {
+		cratesFile = "crates.json"
+	}
+	if skinsFile == "" {
+		skinsFile = "skins.json"
+	}
+
+	var crates []byMykelCrate
+	if err := readCatalogueFile(filepath.Join(s.catalogueDir, filepath.Base(cratesFile)), &crates); err != nil {
+		return report, err
+	}
+	skins := make(map[string]byMykelSkin)
+	var skinList []byMykelSkin
+	if err := readCatalogueFile(filepath.Join(s.catalogueDir, filepath.Base(skinsFile)), &skinList); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return report, err
+	}
+	for _, skin := range skinList {
+		skins[skin.ID] = skin
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, crate := range crates {
+		if !strings.EqualFold(crate.Type, "case") || len(crate.Contains) == 0 {
+			report.Skipped++
+			continue
+		}
+
+		drops := make([]CaseDrop, 0, len(crate.Contains)+len(crate.ContainsRare))
+		for _, entry := range crate.Contains {
+			drops = append(drops, entry.toDrop(skins, false))
+		}
+		for _, entry := range crate.ContainsRare {
+			drops = append(drops, entry.toDrop(skins, true))
+		}
+		applyTierWeights(drops)
+
+		var id int64
+		var inserted bool
+		err := tx.QueryRow(ctx, `
+INSERT INTO case_definitions (name, image_url, price_cents, enabled, source, external_id)
+VALUES ($1, NULLIF($2, ''), $3, TRUE, 'bymykel', $4)
+ON CONFLICT (name)
+DO UPDATE SET image_url = COALESCE(EXCLUDED.image_url, case_definitions.image_url), external_id = EXCLUDED.external_id, updated_at = NOW()
+RETURNING id, xmax = 0
+`, strings.TrimSpace(crate.Name), crate.Image, maxPrice(staticKnownPrice(crate.Name), fallbackByRarity("", "case")), crate.ID).Scan(&id, &inserted)
+		if err != nil {
+			return report, err
+		}
+		if err := replaceCaseDrops(ctx, tx, id, drops); err != nil {
+			return report, err
+		}
+
+		report.Cases++
+		report.Drops += len(drops)
+		if inserted {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return ImportReport{}, err
+	}
+	return report, nil
+}
+
+const premiumPoolCase = "Knife Fever Case"
+
+func premiumAlias(caseName string) string {
+	name := strings.ToLower(caseName)
+	if strings.Contains(name, "knife") || strings.Contains(name, "premium") || strings.Contains(name, "omega") {
+		return premiumPoolCase
+	}
+	return ""
+}
+
+// dropPoolForCase prefers the definition a store purchase recorded in the
+// item metadata, so renaming or disabling it later doesn't change the pool.
+func (s *Service) dropPoolForCase(ctx context.Context, tx pgx.Tx, caseItem Item) (CaseDefinition, []weightedDrop, error) {
+	var meta struct {
+		CaseDefinitionID int64 `json:"case_definition_id"`
+	}
+	_ = json.Unmarshal(caseItem.Metadata, &meta)
+
+	caseName := strings.TrimSpace(caseItem.Name)
+	def, err := scanCaseDefinition(tx.QueryRow(ctx, `
+SELECT `+caseDefinitionColumns+`
+FROM case_definitions
+WHERE id = $3 OR (enabled AND (LOWER(name) = LOWER($1) OR LOWER(name) = LOWER($2) OR is_default))
+ORDER BY (id = $3) DESC, (LOWER(name) = LOWER($1)) DESC, (LOWER(name) = LOWER($2)) DESC
+LIMIT 1
+`, caseName, premiumAlias(caseName), meta.CaseDefinitionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CaseDefinition{}, nil, errors.New("no drop pool configured for this case")
+	}
+	if err != nil {
+		return CaseDefinition{}, nil, err
+	}
+	if meta.CaseDefinitionID > 0 && def.ID != meta.CaseDefinitionID {
+		log.Printf("case definition %d for %q no longer exists, using drop pool %q", meta.CaseDefinitionID, caseName, def.Name)
+	} else if meta.CaseDefinitionID == 0 && !strings.EqualFold(def.Name, caseName) {
+		log.Printf("case %q has no enabled definition, using drop pool %q", caseName, def.Name)
+	}
+
+	drops, err := s.listCaseDrops(ctx, tx, def.ID)
+	if err != nil {
+		return CaseDefinition{}, nil, err
+	}
+	pool := make([]weightedDrop, 0, len(drops))
+	for _, d := range drops {
+		pool = append(pool, weightedDrop{
+			Name:     d.Name,
+			Rarity:   d.Rarity,
+			Weight:   d.Weight,
+			ImageURL: d.ImageURL,
+			MinFloat: d.MinFloat,
+			MaxFloat: d.MaxFloat,
+			StatTrak: d.StatTrak,
+		})
+	}
+	return def, pool, nil
+}
+
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+func (s *Service) listCaseDrops(ctx context.Context, q queryer, caseID int64) ([]CaseDrop, error) {
+	rows, err := q.Query(ctx, `
+SELECT name, rarity, weight, COALESCE(image_url, ''), min_float, max_float, stattrak
+FROM case_definition_drops
+WHERE case_id = $1
+ORDER BY weight DESC, name
+`, caseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drops := make([]CaseDrop, 0)
+	for rows.Next() {
+		var d CaseDrop
+		if err := rows.Scan(&d.Name, &d.Rarity, &d.Weight, &d.ImageURL, &d.MinFloat, &d.MaxFloat, &d.StatTrak); err != nil {
+			return nil, err
+		}
+		drops = append(drops, d)
+	}
+	return drops, rows.Err()
+}
+
+func replaceCaseDrops(ctx context.Context, tx pgx.Tx, caseID int64, drops []CaseDrop) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM case_definition_drops WHERE case_id = $1`, caseID); err != nil {
+		return err
+	}
+	for _, d := range drops {
+		if _, err := tx.Exec(ctx, `
+INSERT INTO case_definition_drops (case_id, name, rarity, weight, image_url, min_float, max_float, stattrak)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+`, caseID, d.Name, d.Rarity, d.Weight, d.ImageURL, d.MinFloat, d.MaxFloat, d.StatTrak); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalizeCaseDefinition(def *CaseDefinition) error {
+	def.Name = strings.TrimSpace(def.Name)
+	def.ImageURL = strings.TrimSpace(def.ImageURL)
+	if def.Name == "" {
+		return errors.New("name is required")
+	}
+	if def.PriceCents < 0 {
+		return errors.New("price_cents cannot be negative")
+	}
+	if def.KeyPriceCents < 0 {
+		return errors.New("key_price_cents cannot be negative")
+	}
+	if len(def.Drops) == 0 {
+		return errors.New("drops are required")
+	}
+	for i := range def.Drops {
+		d := &def.Drops[i]
+		d.Name = strings.TrimSpace(d.Name)
+		d.Rarity = normalizeRarity(d.Rarity)
+		d.ImageURL = strings.TrimSpace(d.ImageURL)
+		if d.Name == "" {
+			return errors.New("every drop needs a name")
+		}
+		if d.Weight <= 0 {
+			return errors.New("drop weight must be positive")
+		}
+		if d.MaxFloat == 0 {
+			d.MaxFloat = 1
+		}
+		if d.MinFloat < 0 || d.MaxFloat > 1 || d.MinFloat >= d.MaxFloat {
+			return errors.New("drop float range must satisfy 0 <= min_float < max_float <= 1")
+		}
+	}
+	return nil
+}
+
+func normalizeRarity(rarity string) string {
+	r := strings.ToLower(strings.TrimSpace(rarity))
+	switch r {
+	case "", "consumer grade", "rarity_common_weapon", "base grade":
+		return "consumer"
+	case "industrial grade", "rarity_uncommon_weapon":
+		return "industrial"
+	case "milspec", "mil-spec grade", "rarity_rare_weapon":
+		return "mil-spec"
+	case "rarity_mythical_weapon":
+		return "restricted"
+	case "rarity_legendary_weapon":
+		return "classified"
+	case "rarity_ancient_weapon":
+		return "covert"
+	case "extraordinary", "rarity_ancient", "rare special", "contraband", "rarity_immortal":
+		return "gold"
+	}
+	return r
+}
+
+func applyTierWeights(drops []CaseDrop) {
+	counts := make(map[string]int64)
+	for _, d := range drops {
+		counts[d.Rarity]++
+	}
+	for i := range drops {
+		tier, ok := importTierWeights[drops[i].Rarity]
+		if !ok {
+			tier = importTierWeights["mil-spec"]
+		}
+		drops[i].Weight = tier * 1000 / counts[drops[i].Rarity]
+		if drops[i].Weight <= 0 {
+			drops[i].Weight = 1
+		}
+	}
+}
+
+type byMykelRarity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type byMykelEntry struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Rarity byMykelRarity `json:"rarity"`
+	Image  string        `json:"image"`
+}
+
+type byMykelCrate struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	Type         string         `json:"type"`
+	Image        string         `json:"image"`
+	Contains     []byMykelEntry `json:"contains"`
+	ContainsRare []byMykelEntry `json:"contains_rare"`
+}
+
+type byMykelSkin struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Rarity   byMykelRarity `json:"rarity"`
+	Image    string        `json:"image"`
+	MinFloat *float64      `json:"min_float"`
+	MaxFloat *float64      `json:"max_float"`
+	StatTrak bool          `json:"stattrak"`
+}
+
+func (e byMykelEntry) toDrop(skins map[string]byMykelSkin, rare bool) CaseDrop {
+	drop := CaseDrop{Name: strings.TrimSpace(e.Name), ImageURL: e.Image, MaxFloat: 1, StatTrak: true}
+	rarity := e.Rarity.ID
+	if skin, ok := skins[e.ID]; ok {
+		if skin.MinFloat != nil && skin.MaxFloat != nil && *skin.MinFloat < *skin.MaxFloat {
+			drop.MinFloat, drop.MaxFloat = *skin.MinFloat, *skin.MaxFloat
+		}
+		drop.StatTrak = skin.StatTrak
+		if rarity == "" {
+			rarity = skin.Rarity.ID
+		}
+		if drop.ImageURL == "" {
+			drop.ImageURL = skin.Image
+		}
+	}
+	if rarity == "" {
+		rarity = e.Rarity.Name
+	}
+	drop.Rarity = normalizeRarity(rarity)
+	if rare {
+		drop.Rarity = "gold"
+	}
+	return drop
+}
+
+func readCatalogueFile(path string, v interface{}) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func scanCaseDefinition(row pgx.Row) (CaseDefinition, error) {
+	var def CaseDefinition
+	err := row.Scan(
+		&def.ID,
+		&def.Name,
+		&def.ImageURL,
+		&def.PriceCents,
+		&def.Enabled,
+		&def.IsDefault,
+		&def.RequiresKey,
+		&def.KeyPriceCents,
+		&def.ForSale,
+		&def.Source,
+		&def.ExternalID,
+		&def.CreatedAt,
+		&def.UpdatedAt,
+	)
+	return def, err
+}
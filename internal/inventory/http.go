@@ -1,6 +1,8 @@
 package inventory
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"strconv"
 
@@ -64,6 +66,36 @@ func (h *Handler) OpenCase(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+type bulkOpenRequest struct {
+	ItemIDs []int64 `json:"item_ids"`
+	Count   int     `json:"count"`
+}
+
+func (h *Handler) OpenCases(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req bulkOpenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	result, err := h.svc.OpenCases(r.Context(), user.ID, req.ItemIDs, req.Count)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"batch_id":     result.BatchID,
+		"opened_cases": result.Opened,
+		"drops":        result.Drops,
+	})
+}
+
 func (h *Handler) SellItem(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -90,3 +122,183 @@ func (h *Handler) SellItem(w http.ResponseWriter, r *http.Request) {
 		"message":        "item sold",
 	})
 }
+
+type storeBuyRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+func (h *Handler) ListStore(w http.ResponseWriter, r *http.Request) {
+	store, err := h.svc.ListStore(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list store")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"cases": store})
+}
+
+func (h *Handler) BuyCases(w http.ResponseWriter, r *http.Request) {
+	h.buyFromStore(w, r, h.svc.BuyCases)
+}
+
+func (h *Handler) BuyKeys(w http.ResponseWriter, r *http.Request) {
+	h.buyFromStore(w, r, h.svc.BuyKeys)
+}
+
+func (h *Handler) buyFromStore(w http.ResponseWriter, r *http.Request, buy func(ctx context.Context, userID, caseID int64, quantity int) ([]Item, int64, error)) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	caseID, err := strconv.ParseInt(chi.URLParam(r, "caseID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid case id")
+		return
+	}
+
+	var req storeBuyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	items, newBalance, err := buy(r.Context(), user.ID, caseID, req.Quantity)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+		"items":         items,
+		"balance_cents": newBalance,
+	})
+}
+
+type importRequest struct {
+	CratesFile string `json:"crates_file"`
+	SkinsFile  string `json:"skins_file"`
+}
+
+func (h *Handler) ListCaseDefinitions(w http.ResponseWriter, r *http.Request) {
+	defs, err := h.svc.ListCaseDefinitions(r.Context(), true)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list case definitions")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"cases": defs})
+}
+
+func (h *Handler) GetCaseDefinition(w http.ResponseWriter, r *http.Request) {
+	caseID, err := strconv.ParseInt(chi.URLParam(r, "caseID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid case id")
+		return
+	}
+
+	def, err := h.svc.GetCaseDefinition(r.Context(), caseID)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"case": def})
+}
+
+func (h *Handler) ListCaseOdds(w http.ResponseWriter, r *http.Request) {
+	odds, err := h.svc.ListCaseOdds(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list case odds")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"cases": odds})
+}
+
+func (h *Handler) GetCaseOdds(w http.ResponseWriter, r *http.Request) {
+	caseID, err := strconv.ParseInt(chi.URLParam(r, "caseID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid case id")
+		return
+	}
+
+	odds, err := h.svc.GetCaseOdds(r.Context(), caseID)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"odds": odds})
+}
+
+func (h *Handler) CreateCaseDefinition(w http.ResponseWriter, r *http.Request) {
+	req := CaseDefinition{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	def, err := h.svc.CreateCaseDefinition(r.Context(), req)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"case": def})
+}
+
+func (h *Handler) UpdateCaseDefinition(w http.ResponseWriter, r *http.Request) {
+	caseID, err := strconv.ParseInt(chi.URLParam(r, "caseID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid case id")
+		return
+	}
+
+	req, err := h.svc.GetCaseDefinition(r.Context(), caseID)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	drops := req.Drops
+	req.Drops = nil
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	if req.Drops == nil {
+		req.Drops = drops
+	}
+
+	def, err := h.svc.UpdateCaseDefinition(r.Context(), caseID, req)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"case": def})
+}
+
+func (h *Handler) DeleteCaseDefinition(w http.ResponseWriter, r *http.Request) {
+	caseID, err := strconv.ParseInt(chi.URLParam(r, "caseID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid case id")
+		return
+	}
+
+	if err := h.svc.DeleteCaseDefinition(r.Context(), caseID); err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"deleted": true})
+}
+
+func (h *Handler) ImportCatalogue(w http.ResponseWriter, r *http.Request) {
+	var req importRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpx.Error(w, http.StatusBadRequest, "invalid json body")
+			return
+		}
+	}
+
+	report, err := h.svc.ImportByMykel(r.Context(), req.CratesFile, req.SkinsFile)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"report": report})
+}
@@ -0,0 +1,267 @@
+package inventory
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type RarityOdds map[string]float64
+
+var DefaultRarityOdds = RarityOdds{
+	"mil-spec":   79.92,
+	"restricted": 15.98,
+	"classified": 3.2,
+	"covert":     0.64,
+	"gold":       0.26,
+}
+
+var rarityOrder = []string{"consumer", "industrial", "mil-spec", "restricted", "classified", "covert", "gold"}
+
+const oddsResolution = 1_000_000
+
+type CaseOdds struct {
+	CaseID   int64      `json:"case_id"`
+	CaseName string     `json:"case_name"`
+	Model    string     `json:"model"`
+	Tiers    []TierOdds `json:"tiers"`
+	Drops    []DropOdds `json:"drops"`
+}
+
+type TierOdds struct {
+	Rarity  string  `json:"rarity"`
+	Percent float64 `json:"percent"`
+	Skins   int     `json:"skins"`
+}
+
+type DropOdds struct {
+	Name     string  `json:"name"`
+	Rarity   string  `json:"rarity"`
+	Percent  float64 `json:"percent"`
+	ImageURL string  `json:"image_url,omitempty"`
+}
+
+func ParseRarityOdds(raw string) (RarityOdds, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		odds := make(RarityOdds, len(DefaultRarityOdds))
+		for rarity, percent := range DefaultRarityOdds {
+			odds[rarity] = percent
+		}
+		return odds, nil
+	}
+
+	odds := make(RarityOdds)
+	total := 0.0
+	for _, part := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, errors.New("rarity odds must look like mil-spec=79.92,restricted=15.98")
+		}
+		rarity := normalizeRarity(key)
+		percent, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || percent < 0 || math.IsNaN(percent) || math.IsInf(percent, 0) {
+			return nil, errors.New("invalid odds for rarity " + rarity)
+		}
+		odds[rarity] = percent
+		total += percent
+	}
+	if total <= 0 {
+		return nil, errors.New("rarity odds must add up to more than zero")
+	}
+	return odds, nil
+}
+
+func (s *Service) ListCaseOdds(ctx context.Context) ([]CaseOdds, error) {
+	defs, err := s.ListCaseDefinitions(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]CaseOdds, 0, len(defs))
+	for _, def := range defs {
+		result = append(result, s.oddsForDefinition(def))
+	}
+	return result, nil
+}
+
+func (s *Service) GetCaseOdds(ctx context.Context, caseID int64) (CaseOdds, error) {
+	def, err := s.GetCaseDefinition(ctx, caseID)
+	if err != nil {
+		return CaseOdds{}, err
+	}
+	if !def.Enabled {
+		return CaseOdds{}, errors.New("case definition not found")
+	}
+	return s.oddsForDefinition(def), nil
+}
+
+func (s *Service) oddsForDefinition(def CaseDefinition) CaseOdds {
+	pool := make([]weightedDrop, 0, len(def.Drops))
+	for _, d := range def.Drops {
+		pool = append(pool, weightedDrop{Name: d.Name, Rarity: d.Rarity, Weight: d.Weight, ImageURL: d.ImageURL})
+	}
+
+	result := CaseOdds{CaseID: def.ID, CaseName: def.Name, Tiers: make([]TierOdds, 0), Drops: make([]DropOdds, 0, len(pool))}
+	tiers, counts := tierWeights(pool, s.odds)
+	if len(tiers) == 0 {
+		result.Model = "weight"
+		total := int64(0)
+		for _, d := range pool {
+			total += d.Weight
+		}
+		tierPercent := make(map[string]float64)
+		for _, d := range pool {
+			percent := 0.0
+			if total > 0 {
+				percent = float64(d.Weight) * 100 / float64(total)
+			}
+			tierPercent[d.Rarity] += percent
+			result.Drops = append(result.Drops, DropOdds{Name: d.Name, Rarity: d.Rarity, Percent: roundPercent(percent), ImageURL: d.ImageURL})
+		}
+		for _, rarity := range orderedRarities(counts) {
+			result.Tiers = append(result.Tiers, TierOdds{Rarity: rarity, Percent: roundPercent(tierPercent[rarity]), Skins: counts[rarity]})
+		}
+		return result
+	}
+
+	result.Model = "rarity"
+	total := int64(0)
+	for _, t := range tiers {
+		total += t.Weight
+	}
+	tierPercent := make(map[string]float64)
+	for _, t := range tiers {
+		tierPercent[t.Rarity] = float64(t.Weight) * 100 / float64(total)
+	}
+	for _, rarity := range orderedRarities(counts) {
+		result.Tiers = append(result.Tiers, TierOdds{Rarity: rarity, Percent: roundPercent(tierPercent[rarity]), Skins: counts[rarity]})
+	}
+	for _, d := range pool {
+		percent := tierPercent[d.Rarity] / float64(counts[d.Rarity])
+		result.Drops = append(result.Drops, DropOdds{Name: d.Name, Rarity: d.Rarity, Percent: roundPercent(percent), ImageURL: d.ImageURL})
+	}
+	return result
+}
+
+func dropChances(pool []weightedDrop, odds RarityOdds) []float64 {
+	chances := make([]float64, len(pool))
+	tiers, counts := tierWeights(pool, odds)
+	if len(tiers) == 0 {
+		total := int64(0)
+		for _, d := range pool {
+			total += d.Weight
+		}
+		for i, d := range pool {
+			if total > 0 {
+				chances[i] = float64(d.Weight) / float64(total)
+			}
+		}
+		return chances
+	}
+
+	total := int64(0)
+	tierWeight := make(map[string]int64, len(tiers))
+	for _, t := range tiers {
+		total += t.Weight
+		tierWeight[t.Rarity] = t.Weight
+	}
+	for i, d := range pool {
+		chances[i] = float64(tierWeight[d.Rarity]) / float64(total) / float64(counts[d.Rarity])
+	}
+	return chances
+}
+
+func chooseDrop(pool []weightedDrop, odds RarityOdds) (weightedDrop, error) {
+	tiers, _ := tierWeights(pool, odds)
+	if len(tiers) == 0 {
+		return chooseWeighted(pool)
+	}
+
+	tier, err := chooseWeighted(tiers)
+	if err != nil {
+		return weightedDrop{}, err
+	}
+	skins := make([]weightedDrop, 0)
+	for _, d := range pool {
+		if d.Rarity == tier.Rarity {
+			skins = append(skins, d)
+		}
+	}
+	r, err := rand.Int(rand.Reader, big.NewInt(int64(len(skins))))
+	if err != nil {
+		return weightedDrop{}, err
+	}
+	return skins[r.Int64()], nil
+}
+
+// tierWeights splits oddsResolution between the tiers in the pool. Tiers
+// missing from the odds table keep the share their catalogue weights have in
+// the whole pool; the tiers that are listed share the rest by their odds.
+func tierWeights(pool []weightedDrop, odds RarityOdds) ([]weightedDrop, map[string]int) {
+	counts := make(map[string]int)
+	totals := make(map[string]int64)
+	var poolTotal int64
+	for _, d := range pool {
+		counts[d.Rarity]++
+		totals[d.Rarity] += d.Weight
+		poolTotal += d.Weight
+	}
+
+	var listedOdds float64
+	var unlistedTotal int64
+	for rarity := range counts {
+		if percent, ok := odds[rarity]; ok {
+			listedOdds += percent
+		} else {
+			unlistedTotal += totals[rarity]
+		}
+	}
+	if listedOdds <= 0 || poolTotal <= 0 {
+		return nil, counts
+	}
+	listedShare := 1 - float64(unlistedTotal)/float64(poolTotal)
+
+	tiers := make([]weightedDrop, 0, len(counts))
+	for _, rarity := range orderedRarities(counts) {
+		share := float64(totals[rarity]) / float64(poolTotal)
+		if percent, ok := odds[rarity]; ok {
+			share = listedShare * percent / listedOdds
+		}
+		weight := int64(math.Round(share * oddsResolution))
+		if weight == 0 && share > 0 {
+			weight = 1
+		}
+		if weight > 0 {
+			tiers = append(tiers, weightedDrop{Rarity: rarity, Weight: weight})
+		}
+	}
+	return tiers, counts
+}
+
+func orderedRarities(counts map[string]int) []string {
+	ordered := make([]string, 0, len(counts))
+	known := make(map[string]bool, len(rarityOrder))
+	for _, rarity := range rarityOrder {
+		known[rarity] = true
+		if counts[rarity] > 0 {
+			ordered = append(ordered, rarity)
+		}
+	}
+	extra := make([]string, 0)
+	for rarity := range counts {
+		if !known[rarity] {
+			extra = append(extra, rarity)
+		}
+	}
+	sort.Strings(extra)
+	return append(ordered, extra...)
+}
+
+func roundPercent(percent float64) float64 {
+	return math.Round(percent*10000) / 10000
+}
@@ -0,0 +1,150 @@
+package inventory
+
+import (
+	"math"
+	"testing"
+)
+
+var standardPool = []weightedDrop{
+	{Name: "UMP-45 | Briefing", Rarity: "mil-spec", Weight: 18},
+	{Name: "AK-47 | Slate", Rarity: "restricted", Weight: 12},
+	{Name: "M4A1-S | Cyrex", Rarity: "classified", Weight: 7},
+	{Name: "AWP | Wildfire", Rarity: "covert", Weight: 3},
+}
+
+func TestTierWeights(t *testing.T) {
+	listed := DefaultRarityOdds["mil-spec"] + DefaultRarityOdds["restricted"] + DefaultRarityOdds["classified"] + DefaultRarityOdds["covert"]
+	tests := []struct {
+		name string
+		pool []weightedDrop
+		odds RarityOdds
+		want map[string]float64
+	}{
+		{
+			name: "seeded standard pool follows the configured odds",
+			pool: standardPool,
+			odds: DefaultRarityOdds,
+			want: map[string]float64{
+				"mil-spec":   DefaultRarityOdds["mil-spec"] / listed,
+				"restricted": DefaultRarityOdds["restricted"] / listed,
+				"classified": DefaultRarityOdds["classified"] / listed,
+				"covert":     DefaultRarityOdds["covert"] / listed,
+			},
+		},
+		{
+			name: "unlisted tiers keep their catalogue share",
+			pool: []weightedDrop{
+				{Name: "a", Rarity: "consumer", Weight: 1},
+				{Name: "b", Rarity: "mil-spec", Weight: 2},
+				{Name: "c", Rarity: "covert", Weight: 1},
+			},
+			odds: RarityOdds{"mil-spec": 75, "covert": 25},
+			want: map[string]float64{"consumer": 0.25, "mil-spec": 0.5625, "covert": 0.1875},
+		},
+		{
+			name: "listed tiers renormalize over the pool",
+			pool: []weightedDrop{
+				{Name: "a", Rarity: "mil-spec", Weight: 1},
+				{Name: "b", Rarity: "covert", Weight: 1},
+			},
+			odds: RarityOdds{"mil-spec": 75, "covert": 25, "gold": 50},
+			want: map[string]float64{"mil-spec": 0.75, "covert": 0.25},
+		},
+		{
+			name: "zero odds exclude a tier",
+			pool: []weightedDrop{
+				{Name: "a", Rarity: "mil-spec", Weight: 1},
+				{Name: "b", Rarity: "gold", Weight: 1},
+			},
+			odds: RarityOdds{"mil-spec": 100, "gold": 0},
+			want: map[string]float64{"mil-spec": 1},
+		},
+		{
+			name: "no listed tiers falls back to weights",
+			pool: []weightedDrop{{Name: "a", Rarity: "consumer", Weight: 3}},
+			odds: DefaultRarityOdds,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiers, _ := tierWeights(tt.pool, tt.odds)
+			if len(tiers) != len(tt.want) {
+				t.Fatalf("got %d tiers, want %d: %+v", len(tiers), len(tt.want), tiers)
+			}
+			for _, tier := range tiers {
+				want, ok := tt.want[tier.Rarity]
+				if !ok {
+					t.Fatalf("unexpected tier %q", tier.Rarity)
+				}
+				if got := float64(tier.Weight) / oddsResolution; math.Abs(got-want) > 1e-6 {
+					t.Errorf("tier %q share = %f, want %f", tier.Rarity, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOddsForDefinitionSplitsTierUniformly(t *testing.T) {
+	s := &Service{odds: RarityOdds{"mil-spec": 80, "covert": 20}}
+	def := CaseDefinition{Drops: []CaseDrop{
+		{Name: "a", Rarity: "mil-spec", Weight: 3},
+		{Name: "b", Rarity: "mil-spec", Weight: 1},
+		{Name: "c", Rarity: "covert", Weight: 5},
+	}}
+	odds := s.oddsForDefinition(def)
+	if odds.Model != "rarity" {
+		t.Fatalf("model = %q, want rarity", odds.Model)
+	}
+	want := map[string]float64{"a": 40, "b": 40, "c": 20}
+	for _, d := range odds.Drops {
+		if d.Percent != want[d.Name] {
+			t.Errorf("drop %q percent = %v, want %v", d.Name, d.Percent, want[d.Name])
+		}
+	}
+}
+
+func TestChooseDropStaysInSelectedTier(t *testing.T) {
+	pool := []weightedDrop{
+		{Name: "a", Rarity: "mil-spec", Weight: 1},
+		{Name: "b", Rarity: "covert", Weight: 1000},
+	}
+	for i := 0; i < 100; i++ {
+		drop, err := chooseDrop(pool, RarityOdds{"mil-spec": 100, "covert": 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drop.Name != "a" {
+			t.Fatalf("chose %q from a tier with zero odds", drop.Name)
+		}
+	}
+}
+
+func TestCheckStorePriceCoversExpectedDropValue(t *testing.T) {
+	s := &Service{odds: RarityOdds{"mil-spec": 80, "covert": 20}}
+	drops := []CaseDrop{
+		{Name: "a", Rarity: "mil-spec", Weight: 1, MinFloat: 0.15, MaxFloat: 0.38},
+		{Name: "b", Rarity: "covert", Weight: 1, MinFloat: 0.15, MaxFloat: 0.38},
+	}
+	if got := s.expectedDropValue(drops); got != 1416 {
+		t.Fatalf("expected drop value = %d, want 1416", got)
+	}
+
+	tests := []struct {
+		name string
+		def  CaseDefinition
+		ok   bool
+	}{
+		{"not for sale", CaseDefinition{PriceCents: 1}, true},
+		{"below expected value", CaseDefinition{ForSale: true, PriceCents: 1415}, false},
+		{"at expected value", CaseDefinition{ForSale: true, PriceCents: 1416}, true},
+		{"key price counts", CaseDefinition{ForSale: true, PriceCents: 1400, RequiresKey: true, KeyPriceCents: 16}, true},
+		{"free", CaseDefinition{ForSale: true}, false},
+	}
+	for _, tt := range tests {
+		tt.def.Drops = drops
+		if err := s.checkStorePrice(tt.def); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
@@ -33,25 +33,39 @@ type Item struct {
 	CreatedAt  time.Time       `json:"created_at"`
 	OpenedAt   *time.Time      `json:"opened_at,omitempty"`
 	SoldAt     *time.Time      `json:"sold_at,omitempty"`
+	Wear       string          `json:"wear,omitempty"`
+	FloatValue *float64        `json:"float_value,omitempty"`
+	StatTrak   bool            `json:"stattrak"`
+	PaintSeed  *int            `json:"paint_seed,omitempty"`
 }
 
+const itemColumns = `id, user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, created_at, opened_at, sold_at, COALESCE(wear, ''), float_value, stattrak, paint_seed`
+
 type Service struct {
-	db      *pgxpool.Pool
-	wallet  *wallet.Service
-	pricing *priceResolver
+	db           *pgxpool.Pool
+	wallet       *wallet.Service
+	pricing      *priceResolver
+	catalogueDir string
+	odds         RarityOdds
 }
 
 type weightedDrop struct {
-	Name   string
-	Rarity string
-	Weight int64
+	Name     string
+	Rarity   string
+	Weight   int64
+	ImageURL string
+	MinFloat float64
+	MaxFloat float64
+	StatTrak bool
 }
 
-func NewService(db *pgxpool.Pool, walletService *wallet.Service) *Service {
+func NewService(db *pgxpool.Pool, walletService *wallet.Service, catalogueDir string, odds RarityOdds) *Service {
 	return &Service{
-		db:      db,
-		wallet:  walletService,
-		pricing: newPriceResolver(),
+		db:           db,
+		wallet:       walletService,
+		pricing:      newPriceResolver(),
+		catalogueDir: catalogueDir,
+		odds:         odds,
 	}
 }
 
@@ -74,7 +88,7 @@ func (s *Service) GrantItem(ctx context.Context, userID int64, itemType, name, r
 	}
 	priceCents := extractPriceCents(metadata)
 	if priceCents <= 0 {
-		priceCents = s.resolveItemPrice(ctx, itemType, name, rarity, 0)
+		priceCents = s.resolveItemPrice(ctx, itemType, name, rarity, nil, 0)
 	}
 	metadata["price_cents"] = priceCents
 
@@ -88,26 +102,11 @@ func (s *Service) GrantItem(ctx context.Context, userID int64, itemType, name, r
 		status = "unopened"
 	}
 
-	var item Item
-	err = s.db.QueryRow(ctx, `
+	item, err := scanItem(s.db.QueryRow(ctx, `
 INSERT INTO inventory_items (user_id, item_type, name, rarity, price_cents, status, source, metadata)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, created_at, opened_at, sold_at
-`, userID, itemType, name, rarity, priceCents, status, source, rawMeta).Scan(
-		&item.ID,
-		&item.UserID,
-		&item.ItemType,
-		&item.Name,
-		&item.Rarity,
-		&item.PriceCents,
-		&item.Status,
-		&item.Source,
-		&item.ParentItem,
-		&item.Metadata,
-		&item.CreatedAt,
-		&item.OpenedAt,
-		&item.SoldAt,
-	)
+RETURNING `+itemColumns+`
+`, userID, itemType, name, rarity, priceCents, status, source, rawMeta))
 	return item, err
 }
 
@@ -117,10 +116,10 @@ func (s *Service) ListByUser(ctx context.Context, userID int64, limit int) ([]It
 	}
 
 	rows, err := s.db.Query(ctx, `
-SELECT id, user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, created_at, opened_at, sold_at
+SELECT `+itemColumns+`
 FROM inventory_items
 WHERE user_id = $1
-  AND status <> 'sold'
+  AND status NOT IN ('sold', 'used')
   AND NOT (item_type = 'case' AND status = 'opened')
 ORDER BY created_at DESC
 LIMIT $2
@@ -132,8 +131,8 @@ LIMIT $2
 
 	items := make([]Item, 0)
 	for rows.Next() {
-		var item Item
-		if err := rows.Scan(&item.ID, &item.UserID, &item.ItemType, &item.Name, &item.Rarity, &item.PriceCents, &item.Status, &item.Source, &item.ParentItem, &item.Metadata, &item.CreatedAt, &item.OpenedAt, &item.SoldAt); err != nil {
+		item, err := scanItem(rows)
+		if err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -148,70 +147,82 @@ func (s *Service) OpenCase(ctx context.Context, userID, caseItemID int64) (Item,
 	}
 	defer tx.Rollback(ctx)
 
-	var caseItem Item
-	err = tx.QueryRow(ctx, `
-SELECT id, user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, created_at, opened_at, sold_at
+	caseItem, err := scanItem(tx.QueryRow(ctx, `
+SELECT `+itemColumns+`
 FROM inventory_items
 WHERE id = $1 AND user_id = $2
 FOR UPDATE
-`, caseItemID, userID).Scan(
-		&caseItem.ID,
-		&caseItem.UserID,
-		&caseItem.ItemType,
-		&caseItem.Name,
-		&caseItem.Rarity,
-		&caseItem.PriceCents,
-		&caseItem.Status,
-		&caseItem.Source,
-		&caseItem.ParentItem,
-		&caseItem.Metadata,
-		&caseItem.CreatedAt,
-		&caseItem.OpenedAt,
-		&caseItem.SoldAt,
-	)
+`, caseItemID, userID))
 	if err != nil {
 		return Item{}, Item{}, err
 	}
+
+	caseItem, skin, err := s.openCaseTx(ctx, tx, userID, caseItem, "")
+	if err != nil {
+		return Item{}, Item{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Item{}, Item{}, err
+	}
+	s.warmDropPrices([]Item{skin})
+	return caseItem, skin, nil
+}
+
+func (s *Service) openCaseTx(ctx context.Context, tx pgx.Tx, userID int64, caseItem Item, batchID string) (Item, Item, error) {
 	if caseItem.ItemType != "case" || caseItem.Status != "unopened" {
 		return Item{}, Item{}, errors.New("item is not an unopened case")
 	}
+	caseItemID := caseItem.ID
 
-	drop, err := chooseDrop(dropPoolForCase(caseItem.Name))
+	def, pool, err := s.dropPoolForCase(ctx, tx, caseItem)
 	if err != nil {
 		return Item{}, Item{}, err
 	}
-	dropPrice := s.resolveItemPrice(ctx, "skin", drop.Name, drop.Rarity, 0)
-	dropMeta, _ := json.Marshal(map[string]interface{}{
-		"from_case_id":   caseItemID,
-		"from_case_name": caseItem.Name,
-		"price_cents":    dropPrice,
-	})
+	keyMeta, err := s.consumeKey(ctx, tx, userID, caseItemID, def)
+	if err != nil {
+		return Item{}, Item{}, err
+	}
+	drop, err := chooseDrop(pool, s.odds)
+	if err != nil {
+		return Item{}, Item{}, err
+	}
+	attrs, err := rollAttributes(drop)
+	if err != nil {
+		return Item{}, Item{}, err
+	}
+	dropPrice := s.quoteItemPrice("skin", drop.Name, drop.Rarity, &attrs)
+	dropMetaMap := map[string]interface{}{
+		"from_case_id":       caseItemID,
+		"from_case_name":     caseItem.Name,
+		"case_definition_id": def.ID,
+		"market_hash_name":   marketHashName(drop.Name, drop.Rarity, &attrs),
+		"price_cents":        dropPrice,
+	}
+	if drop.ImageURL != "" {
+		dropMetaMap["image_url"] = drop.ImageURL
+	}
+	if batchID != "" {
+		dropMetaMap["batch_id"] = batchID
+	}
+	dropMeta, _ := json.Marshal(dropMetaMap)
 
-	var skin Item
-	err = tx.QueryRow(ctx, `
-INSERT INTO inventory_items (user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata)
-VALUES ($1, 'skin', $2, $3, $4, 'available', 'case_open', $5, $6)
-RETURNING id, user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, created_at, opened_at, sold_at
-`, userID, drop.Name, drop.Rarity, dropPrice, caseItemID, dropMeta).Scan(
-		&skin.ID,
-		&skin.UserID,
-		&skin.ItemType,
-		&skin.Name,
-		&skin.Rarity,
-		&skin.PriceCents,
-		&skin.Status,
-		&skin.Source,
-		&skin.ParentItem,
-		&skin.Metadata,
-		&skin.CreatedAt,
-		&skin.OpenedAt,
-		&skin.SoldAt,
-	)
+	skin, err := scanItem(tx.QueryRow(ctx, `
+INSERT INTO inventory_items (user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, wear, float_value, stattrak, paint_seed)
+VALUES ($1, 'skin', $2, $3, $4, 'available', 'case_open', $5, $6, $7, $8, $9, $10)
+RETURNING `+itemColumns+`
+`, userID, drop.Name, drop.Rarity, dropPrice, caseItemID, dropMeta, attrs.Wear, attrs.FloatValue, attrs.StatTrak, attrs.PaintSeed))
 	if err != nil {
 		return Item{}, Item{}, err
 	}
 
-	openedMeta, _ := json.Marshal(map[string]interface{}{"opened_item_id": skin.ID, "opened_item_name": skin.Name, "opened_item_rarity": skin.Rarity})
+	openedMetaMap := map[string]interface{}{"opened_item_id": skin.ID, "opened_item_name": skin.Name, "opened_item_rarity": skin.Rarity}
+	for k, v := range keyMeta {
+		openedMetaMap[k] = v
+	}
+	if batchID != "" {
+		openedMetaMap["batch_id"] = batchID
+	}
+	openedMeta, _ := json.Marshal(openedMetaMap)
 	_, err = tx.Exec(ctx, `
 UPDATE inventory_items
 SET status = 'opened', opened_at = NOW(), metadata = $2
@@ -225,48 +236,10 @@ WHERE id = $1
 	caseItem.Status = "opened"
 	caseItem.OpenedAt = &now
 	caseItem.Metadata = openedMeta
-
-	if err := tx.Commit(ctx); err != nil {
-		return Item{}, Item{}, err
-	}
 	return caseItem, skin, nil
 }
 
-func defaultDropPool() []weightedDrop {
-	return []weightedDrop{
-		{Name: "P250 | Sand Dune", Rarity: "consumer", Weight: 35},
-		{Name: "MP9 | Storm", Rarity: "industrial", Weight: 25},
-		{Name: "UMP-45 | Briefing", Rarity: "mil-spec", Weight: 18},
-		{Name: "AK-47 | Slate", Rarity: "restricted", Weight: 12},
-		{Name: "M4A1-S | Cyrex", Rarity: "classified", Weight: 7},
-		{Name: "AWP | Wildfire", Rarity: "covert", Weight: 3},
-	}
-}
-
-func knifePremiumDropPool() []weightedDrop {
-	// Premium knife case: better chances for expensive tiers versus default pool.
-	return []weightedDrop{
-		{Name: "P250 | Sand Dune", Rarity: "consumer", Weight: 18},
-		{Name: "MP9 | Storm", Rarity: "industrial", Weight: 16},
-		{Name: "UMP-45 | Briefing", Rarity: "mil-spec", Weight: 18},
-		{Name: "AK-47 | Slate", Rarity: "restricted", Weight: 18},
-		{Name: "M4A1-S | Cyrex", Rarity: "classified", Weight: 14},
-		{Name: "AWP | Wildfire", Rarity: "covert", Weight: 11},
-		{Name: "Karambit | Doppler", Rarity: "gold", Weight: 2},
-		{Name: "M9 Bayonet | Fade", Rarity: "gold", Weight: 2},
-		{Name: "Butterfly Knife | Slaughter", Rarity: "gold", Weight: 1},
-	}
-}
-
-func dropPoolForCase(caseName string) []weightedDrop {
-	name := strings.ToLower(strings.TrimSpace(caseName))
-	if strings.Contains(name, "knife") || strings.Contains(name, "premium") || strings.Contains(name, "omega") {
-		return knifePremiumDropPool()
-	}
-	return defaultDropPool()
-}
-
-func chooseDrop(pool []weightedDrop) (weightedDrop, error) {
+func chooseWeighted(pool []weightedDrop) (weightedDrop, error) {
 	total := int64(0)
 	for _, it := range pool {
 		total += it.Weight
@@ -297,7 +270,7 @@ func (s *Service) GrantItemTx(ctx context.Context, tx pgx.Tx, userID int64, item
 	}
 	priceCents := extractPriceCents(metadata)
 	if priceCents <= 0 {
-		priceCents = s.resolveItemPrice(ctx, itemType, name, rarity, 0)
+		priceCents = s.quoteItemPrice(itemType, name, rarity, nil)
 	}
 	metadata["price_cents"] = priceCents
 	rawMeta, err := json.Marshal(metadata)
@@ -308,26 +281,11 @@ func (s *Service) GrantItemTx(ctx context.Context, tx pgx.Tx, userID int64, item
 	if itemType == "case" {
 		status = "unopened"
 	}
-	var item Item
-	err = tx.QueryRow(ctx, `
+	item, err := scanItem(tx.QueryRow(ctx, `
 INSERT INTO inventory_items (user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-RETURNING id, user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, created_at, opened_at, sold_at
-`, userID, itemType, name, rarity, priceCents, status, source, parentItemID, rawMeta).Scan(
-		&item.ID,
-		&item.UserID,
-		&item.ItemType,
-		&item.Name,
-		&item.Rarity,
-		&item.PriceCents,
-		&item.Status,
-		&item.Source,
-		&item.ParentItem,
-		&item.Metadata,
-		&item.CreatedAt,
-		&item.OpenedAt,
-		&item.SoldAt,
-	)
+RETURNING `+itemColumns+`
+`, userID, itemType, name, rarity, priceCents, status, source, parentItemID, rawMeta))
 	return item, err
 }
 
@@ -342,27 +300,12 @@ func (s *Service) SellItem(ctx context.Context, userID, itemID int64) (Item, int
 	}
 	defer tx.Rollback(ctx)
 
-	var item Item
-	err = tx.QueryRow(ctx, `
-SELECT id, user_id, item_type, name, rarity, price_cents, status, source, parent_item_id, metadata, created_at, opened_at, sold_at
+	item, err := scanItem(tx.QueryRow(ctx, `
+SELECT `+itemColumns+`
 FROM inventory_items
 WHERE id = $1 AND user_id = $2
 FOR UPDATE
-`, itemID, userID).Scan(
-		&item.ID,
-		&item.UserID,
-		&item.ItemType,
-		&item.Name,
-		&item.Rarity,
-		&item.PriceCents,
-		&item.Status,
-		&item.Source,
-		&item.ParentItem,
-		&item.Metadata,
-		&item.CreatedAt,
-		&item.OpenedAt,
-		&item.SoldAt,
-	)
+`, itemID, userID))
 	if err != nil {
 		return Item{}, 0, err
 	}
@@ -372,11 +315,13 @@ FOR UPDATE
 		return Item{}, 0, errors.New("item already sold")
 	case "opened":
 		return Item{}, 0, errors.New("opened case cannot be sold")
+	case "used":
+		return Item{}, 0, errors.New("used key cannot be sold")
 	}
 
 	saleAmount := item.PriceCents
 	if saleAmount <= 0 {
-		saleAmount = s.resolveItemPrice(ctx, item.ItemType, item.Name, item.Rarity, 0)
+		saleAmount = s.resolveItemPrice(ctx, item.ItemType, item.Name, item.Rarity, item.attributes(), 0)
 	}
 	if saleAmount <= 0 {
 		return Item{}, 0, errors.New("item has no market price")
@@ -446,7 +391,16 @@ func newPriceResolver() *priceResolver {
 	}
 }
 
-func (s *Service) resolveItemPrice(ctx context.Context, itemType, name, rarity string, defaultCents int64) int64 {
+func (s *Service) resolveItemPrice(ctx context.Context, itemType, name, rarity string, attrs *SkinAttributes, defaultCents int64) int64 {
+	if attrs != nil {
+		if s.pricing != nil {
+			if p := s.pricing.resolveMarket(ctx, marketHashName(name, rarity, attrs)); p > 0 {
+				return p
+			}
+		}
+		base := s.resolveItemPrice(ctx, itemType, name, rarity, nil, defaultCents)
+		return int64(float64(base)*attrs.priceFactor() + 0.5)
+	}
 	if s.pricing == nil {
 		return maxPrice(defaultCents, fallbackByRarity(rarity, itemType))
 	}
@@ -456,18 +410,82 @@ func (s *Service) resolveItemPrice(ctx context.Context, itemType, name, rarity s
 	return maxPrice(defaultCents, fallbackByRarity(rarity, itemType))
 }
 
+// quoteItemPrice prices an item without any network call, from the price
+// cache, the static price list and the rarity fallback. Use it while rows are
+// locked; warmDropPrices refreshes the cache after the transaction commits.
+func (s *Service) quoteItemPrice(itemType, name, rarity string, attrs *SkinAttributes) int64 {
+	base := staticKnownPrice(name)
+	if base <= 0 {
+		base = fallbackByRarity(rarity, itemType)
+	}
+	if s.pricing != nil {
+		if attrs != nil {
+			if p, ok := s.pricing.cached(marketCacheKey(marketHashName(name, rarity, attrs))); ok && p > 0 {
+				return p
+			}
+		}
+		if p, ok := s.pricing.cached(itemCacheKey(itemType, name)); ok && p > 0 {
+			base = p
+		}
+	}
+	if attrs == nil {
+		return base
+	}
+	return int64(float64(base)*attrs.priceFactor() + 0.5)
+}
+
+func (s *Service) warmDropPrices(drops []Item) {
+	if s.pricing == nil || len(drops) == 0 {
+		return
+	}
+	names := make([]string, 0, len(drops))
+	seen := make(map[string]bool, len(drops))
+	for _, d := range drops {
+		name := marketHashName(d.Name, d.Rarity, d.attributes())
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for _, name := range names {
+			if ctx.Err() != nil {
+				return
+			}
+			s.pricing.resolveMarket(ctx, name)
+		}
+	}()
+}
+
+func itemCacheKey(itemType, name string) string {
+	return strings.ToLower(strings.TrimSpace(itemType + "|" + name))
+}
+
+func marketCacheKey(marketName string) string {
+	return strings.ToLower("market|" + strings.TrimSpace(marketName))
+}
+
+func (r *priceResolver) cached(key string) (int64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cached, ok := r.cache[key]
+	if !ok || !time.Now().Before(cached.expiresAt) {
+		return 0, false
+	}
+	return cached.priceCents, true
+}
+
 func (r *priceResolver) resolve(ctx context.Context, itemType, name, rarity string) int64 {
-	key := strings.ToLower(strings.TrimSpace(itemType + "|" + name))
+	key := itemCacheKey(itemType, name)
 	if key == "|" {
 		return 0
 	}
 
-	r.mu.RLock()
-	if cached, ok := r.cache[key]; ok && time.Now().Before(cached.expiresAt) {
-		r.mu.RUnlock()
-		return cached.priceCents
+	if p, ok := r.cached(key); ok {
+		return p
 	}
-	r.mu.RUnlock()
 
 	if p := staticKnownPrice(name); p > 0 {
 		r.setCache(key, p)
@@ -486,6 +504,17 @@ func (r *priceResolver) resolve(ctx context.Context, itemType, name, rarity stri
 	return fallback
 }
 
+func (r *priceResolver) resolveMarket(ctx context.Context, marketName string) int64 {
+	key := marketCacheKey(marketName)
+	if p, ok := r.cached(key); ok {
+		return p
+	}
+
+	p := r.fetchSteamPrice(ctx, marketName)
+	r.setCache(key, p)
+	return p
+}
+
 func (r *priceResolver) setCache(key string, price int64) {
 	r.mu.Lock()
 	r.cache[key] = cachedPrice{
@@ -639,3 +668,38 @@ func extractPriceCents(metadata map[string]interface{}) int64 {
 		return 0
 	}
 }
+
+func (item Item) attributes() *SkinAttributes {
+	if item.Wear == "" || item.FloatValue == nil {
+		return nil
+	}
+	attrs := &SkinAttributes{Wear: item.Wear, FloatValue: *item.FloatValue, StatTrak: item.StatTrak}
+	if item.PaintSeed != nil {
+		attrs.PaintSeed = *item.PaintSeed
+	}
+	return attrs
+}
+
+func scanItem(row pgx.Row) (Item, error) {
+	var item Item
+	err := row.Scan(
+		&item.ID,
+		&item.UserID,
+		&item.ItemType,
+		&item.Name,
+		&item.Rarity,
+		&item.PriceCents,
+		&item.Status,
+		&item.Source,
+		&item.ParentItem,
+		&item.Metadata,
+		&item.CreatedAt,
+		&item.OpenedAt,
+		&item.SoldAt,
+		&item.Wear,
+		&item.FloatValue,
+		&item.StatTrak,
+		&item.PaintSeed,
+	)
+	return item, err
+}
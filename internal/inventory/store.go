@@ -0,0 +1,221 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const maxStoreQuantity = 20
+
+type StoreCase struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	ImageURL      string `json:"image_url,omitempty"`
+	PriceCents    int64  `json:"price_cents"`
+	RequiresKey   bool   `json:"requires_key"`
+	KeyName       string `json:"key_name,omitempty"`
+	KeyPriceCents int64  `json:"key_price_cents"`
+}
+
+func (s *Service) ListStore(ctx context.Context) ([]StoreCase, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT `+caseDefinitionColumns+`
+FROM case_definitions
+WHERE enabled AND for_sale AND price_cents > 0
+ORDER BY price_cents, name
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	store := make([]StoreCase, 0)
+	for rows.Next() {
+		def, err := scanCaseDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		store = append(store, storeCase(def))
+	}
+	return store, rows.Err()
+}
+
+func (s *Service) BuyCases(ctx context.Context, userID, caseID int64, quantity int) ([]Item, int64, error) {
+	return s.buyFromStore(ctx, userID, caseID, quantity, "case")
+}
+
+func (s *Service) BuyKeys(ctx context.Context, userID, caseID int64, quantity int) ([]Item, int64, error) {
+	return s.buyFromStore(ctx, userID, caseID, quantity, "key")
+}
+
+func (s *Service) buyFromStore(ctx context.Context, userID, caseID int64, quantity int, itemType string) ([]Item, int64, error) {
+	if s.wallet == nil {
+		return nil, 0, errors.New("wallet service is not configured")
+	}
+	if quantity <= 0 {
+		quantity = 1
+	}
+	if quantity > maxStoreQuantity {
+		return nil, 0, errors.New("you can buy at most 20 at once")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	def, err := scanCaseDefinition(tx.QueryRow(ctx, `SELECT `+caseDefinitionColumns+` FROM case_definitions WHERE id = $1 AND enabled AND for_sale`, caseID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, errors.New("case not found")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	def.Drops, err = s.listCaseDrops(ctx, tx, def.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.checkStorePrice(def); err != nil {
+		log.Printf("store sale of case %d refused: %v", def.ID, err)
+		return nil, 0, errors.New("this item is not for sale")
+	}
+
+	name, unitPrice, reason := def.Name, def.PriceCents, "store_case_purchase"
+	if itemType == "key" {
+		if !def.RequiresKey {
+			return nil, 0, errors.New("this case does not need a key")
+		}
+		name, unitPrice, reason = keyName(def.Name), def.KeyPriceCents, "store_key_purchase"
+	}
+	if unitPrice <= 0 {
+		return nil, 0, errors.New("this item is not for sale")
+	}
+
+	total := unitPrice * int64(quantity)
+	balance, err := s.wallet.AdjustBalance(ctx, tx, userID, -total, reason, map[string]interface{}{
+		"case_definition_id": def.ID,
+		"item_name":          name,
+		"quantity":           quantity,
+		"unit_price_cents":   unitPrice,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]Item, 0, quantity)
+	for i := 0; i < quantity; i++ {
+		item, err := s.GrantItemTx(ctx, tx, userID, itemType, name, "restricted", "store", nil, map[string]interface{}{
+			"case_definition_id": def.ID,
+			"price_cents":        unitPrice,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, err
+	}
+	return items, balance, nil
+}
+
+func (s *Service) consumeKey(ctx context.Context, tx pgx.Tx, userID, caseItemID int64, def CaseDefinition) (map[string]interface{}, error) {
+	if !def.RequiresKey {
+		return nil, nil
+	}
+
+	var keyItemID int64
+	err := tx.QueryRow(ctx, `
+SELECT id
+FROM inventory_items
+WHERE user_id = $1 AND item_type = 'key' AND status = 'available' AND LOWER(name) = LOWER($2)
+ORDER BY created_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`, userID, keyName(def.Name)).Scan(&keyItemID)
+	if err == nil {
+		if _, err := tx.Exec(ctx, `
+UPDATE inventory_items
+SET status = 'used', opened_at = NOW(), metadata = metadata || jsonb_build_object('used_on_case_id', $2::bigint)
+WHERE id = $1
+`, keyItemID, caseItemID); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"key": "item", "key_item_id": keyItemID}, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	if def.KeyPriceCents <= 0 || s.wallet == nil {
+		return nil, errors.New("you need a " + keyName(def.Name) + " to open this case")
+	}
+	if _, err := s.wallet.AdjustBalance(ctx, tx, userID, -def.KeyPriceCents, "case_key", map[string]interface{}{
+		"case_item_id":       caseItemID,
+		"case_definition_id": def.ID,
+		"key_price_cents":    def.KeyPriceCents,
+	}); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"key": "wallet", "key_price_cents": def.KeyPriceCents}, nil
+}
+
+// checkStorePrice refuses store listings that cost less to open than the
+// expected value of their drops, since drops can be sold back at that value.
+func (s *Service) checkStorePrice(def CaseDefinition) error {
+	if !def.ForSale {
+		return nil
+	}
+	if def.PriceCents <= 0 {
+		return errors.New("for_sale cases need a price_cents above zero")
+	}
+	cost := def.PriceCents
+	if def.RequiresKey {
+		cost += def.KeyPriceCents
+	}
+	if expected := s.expectedDropValue(def.Drops); cost < expected {
+		return fmt.Errorf("price_cents (plus key_price_cents) must be at least the expected drop value of %d cents", expected)
+	}
+	return nil
+}
+
+func (s *Service) expectedDropValue(drops []CaseDrop) int64 {
+	pool := make([]weightedDrop, 0, len(drops))
+	for _, d := range drops {
+		pool = append(pool, weightedDrop{Name: d.Name, Rarity: d.Rarity, Weight: d.Weight, MinFloat: d.MinFloat, MaxFloat: d.MaxFloat, StatTrak: d.StatTrak})
+	}
+
+	chances := dropChances(pool, s.odds)
+	value := 0.0
+	for i, d := range pool {
+		value += chances[i] * float64(s.quoteItemPrice("skin", d.Name, d.Rarity, nil)) * expectedPriceFactor(d)
+	}
+	return int64(math.Ceil(value))
+}
+
+func storeCase(def CaseDefinition) StoreCase {
+	sc := StoreCase{
+		ID:          def.ID,
+		Name:        def.Name,
+		ImageURL:    def.ImageURL,
+		PriceCents:  def.PriceCents,
+		RequiresKey: def.RequiresKey,
+	}
+	if def.RequiresKey {
+		sc.KeyName = keyName(def.Name)
+		sc.KeyPriceCents = def.KeyPriceCents
+	}
+	return sc
+}
+
+func keyName(caseName string) string {
+	return strings.TrimSuffix(strings.TrimSpace(caseName), " Case") + " Case Key"
+}
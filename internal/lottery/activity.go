@@ -0,0 +1,204 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	ActivityJoin         = "join"
+	ActivityPresence     = "presence"
+	ActivityContribution = "contribution"
+	ActivityChatMessage  = "chat_message"
+	ActivityGSIPacket    = "gsi_packet"
+	ActivityLotteryJoin  = "lottery_join"
+)
+
+type ActivityType struct {
+	Key             string    `json:"key"`
+	Label           string    `json:"label"`
+	Points          int64     `json:"points"`
+	CooldownSeconds int       `json:"cooldown_seconds"`
+	Enabled         bool      `json:"enabled"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ActivityBreakdown struct {
+	ActivityType  string  `json:"activity_type"`
+	Label         string  `json:"label"`
+	Count         int64   `json:"count"`
+	Points        int64   `json:"points"`
+	DecayedPoints float64 `json:"decayed_points"`
+}
+
+type ActivityEvent struct {
+	ID              int64     `json:"id"`
+	StreamSessionID *int64    `json:"stream_session_id,omitempty"`
+	ActivityType    string    `json:"activity_type"`
+	Points          int64     `json:"points"`
+	DecayedPoints   float64   `json:"decayed_points"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ActivityExplanation struct {
+	UserID          int64               `json:"user_id"`
+	StreamSessionID *int64              `json:"stream_session_id,omitempty"`
+	Score           int64               `json:"score"`
+	HalfLifeHours   float64             `json:"half_life_hours"`
+	Breakdown       []ActivityBreakdown `json:"breakdown"`
+	Recent          []ActivityEvent     `json:"recent"`
+}
+
+const decayedPointsSQL = `e.points * CASE WHEN $2::float8 > 0 THEN power(0.5, EXTRACT(EPOCH FROM NOW() - e.created_at) / $2::float8) ELSE 1 END`
+
+func (s *Service) RecordActivity(ctx context.Context, userID int64, streamSessionID *int64, activityType string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended(format('activity:%s:%s:%s', $1::bigint, $2::bigint, $3::text), 0))`, userID, streamSessionID, activityType); err != nil {
+		return err
+	}
+
+	var points int64
+	err = tx.QueryRow(ctx, `
+INSERT INTO viewer_activity_events (user_id, stream_session_id, activity_type, points)
+SELECT $1, $2, t.key, t.points
+FROM activity_types t
+WHERE t.key = $3 AND t.enabled AND t.points > 0
+  AND NOT EXISTS (
+      SELECT 1 FROM viewer_activity_events e
+      WHERE e.user_id = $1 AND e.activity_type = t.key
+        AND e.stream_session_id IS NOT DISTINCT FROM $2::bigint
+        AND e.created_at > NOW() - make_interval(secs => t.cooldown_seconds)
+  )
+RETURNING points
+`, userID, streamSessionID, activityType).Scan(&points)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+INSERT INTO viewer_activity (user_id, score, updated_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id)
+DO UPDATE SET score = viewer_activity.score + EXCLUDED.score, updated_at = NOW()
+`, userID, points); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Service) Join(ctx context.Context, userID int64) error {
+	return s.RecordActivity(ctx, userID, nil, ActivityLotteryJoin)
+}
+
+func (s *Service) ListActivityTypes(ctx context.Context) ([]ActivityType, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT key, label, points, cooldown_seconds, enabled, updated_at
+FROM activity_types
+ORDER BY key
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make([]ActivityType, 0)
+	for rows.Next() {
+		var t ActivityType
+		if err := rows.Scan(&t.Key, &t.Label, &t.Points, &t.CooldownSeconds, &t.Enabled, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+func (s *Service) UpdateActivityType(ctx context.Context, key string, points int64, cooldownSeconds int, enabled bool) (ActivityType, error) {
+	if points < 0 {
+		return ActivityType{}, errors.New("points cannot be negative")
+	}
+	if cooldownSeconds < 0 {
+		return ActivityType{}, errors.New("cooldown_seconds cannot be negative")
+	}
+
+	var t ActivityType
+	err := s.db.QueryRow(ctx, `
+UPDATE activity_types
+SET points = $2, cooldown_seconds = $3, enabled = $4, updated_at = NOW()
+WHERE key = $1
+RETURNING key, label, points, cooldown_seconds, enabled, updated_at
+`, strings.ToLower(strings.TrimSpace(key)), points, cooldownSeconds, enabled).Scan(&t.Key, &t.Label, &t.Points, &t.CooldownSeconds, &t.Enabled, &t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ActivityType{}, errors.New("activity type not found")
+	}
+	return t, err
+}
+
+func (s *Service) ExplainActivity(ctx context.Context, userID int64, streamSessionID *int64) (ActivityExplanation, error) {
+	explanation := ActivityExplanation{
+		UserID:          userID,
+		StreamSessionID: streamSessionID,
+		HalfLifeHours:   s.activityHalfLife.Hours(),
+		Breakdown:       make([]ActivityBreakdown, 0),
+		Recent:          make([]ActivityEvent, 0),
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT e.activity_type, COALESCE(t.label, e.activity_type), COUNT(*), SUM(e.points), SUM(`+decayedPointsSQL+`)
+FROM viewer_activity_events e
+LEFT JOIN activity_types t ON t.key = e.activity_type
+WHERE e.user_id = $1 AND ($3::bigint IS NULL OR e.stream_session_id = $3)
+GROUP BY e.activity_type, t.label
+ORDER BY 5 DESC
+`, userID, s.activityHalfLife.Seconds(), streamSessionID)
+	if err != nil {
+		return ActivityExplanation{}, err
+	}
+	defer rows.Close()
+
+	total := 0.0
+	for rows.Next() {
+		var b ActivityBreakdown
+		if err := rows.Scan(&b.ActivityType, &b.Label, &b.Count, &b.Points, &b.DecayedPoints); err != nil {
+			return ActivityExplanation{}, err
+		}
+		total += b.DecayedPoints
+		explanation.Breakdown = append(explanation.Breakdown, b)
+	}
+	if err := rows.Err(); err != nil {
+		return ActivityExplanation{}, err
+	}
+	explanation.Score = int64(total)
+
+	recent, err := s.db.Query(ctx, `
+SELECT e.id, e.stream_session_id, e.activity_type, e.points, `+decayedPointsSQL+`, e.created_at
+FROM viewer_activity_events e
+WHERE e.user_id = $1 AND ($3::bigint IS NULL OR e.stream_session_id = $3)
+ORDER BY e.created_at DESC
+LIMIT 20
+`, userID, s.activityHalfLife.Seconds(), streamSessionID)
+	if err != nil {
+		return ActivityExplanation{}, err
+	}
+	defer recent.Close()
+
+	for recent.Next() {
+		var e ActivityEvent
+		if err := recent.Scan(&e.ID, &e.StreamSessionID, &e.ActivityType, &e.Points, &e.DecayedPoints, &e.CreatedAt); err != nil {
+			return ActivityExplanation{}, err
+		}
+		explanation.Recent = append(explanation.Recent, e)
+	}
+	return explanation, recent.Err()
+}
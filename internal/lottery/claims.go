@@ -0,0 +1,279 @@
+package lottery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	ClaimPending = "pending"
+	ClaimClaimed = "claimed"
+	ClaimExpired = "expired"
+)
+
+const claimRoundColumns = `id, trigger_event_id, case_id, stream_session_id, winner_user_id, trigger_type, prize_cents, details, prize_item_id, COALESCE(claim_status, ''), claim_expires_at, redraw_of_round_id, redrawn_round_id, created_at`
+
+func openClaim(ctx context.Context, tx pgx.Tx, round *Round, window time.Duration) error {
+	return tx.QueryRow(ctx, `
+UPDATE lottery_rounds
+SET claim_status = 'pending', claim_expires_at = NOW() + make_interval(secs => $2)
+WHERE id = $1
+RETURNING claim_status, claim_expires_at
+`, round.ID, window.Seconds()).Scan(&round.ClaimStatus, &round.ClaimExpiresAt)
+}
+
+func (s *Service) GetRound(ctx context.Context, roundID int64) (Round, error) {
+	round, err := scanClaimRound(s.db.QueryRow(ctx, `SELECT `+claimRoundColumns+` FROM lottery_rounds WHERE id = $1`, roundID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Round{}, errors.New("round not found")
+	}
+	return round, err
+}
+
+func (s *Service) ListPendingClaims(ctx context.Context, userID int64) ([]Round, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT `+claimRoundColumns+`
+FROM lottery_rounds
+WHERE winner_user_id = $1 AND claim_status = 'pending' AND claim_expires_at > NOW()
+ORDER BY claim_expires_at
+`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rounds := make([]Round, 0)
+	for rows.Next() {
+		round, err := scanClaimRound(rows)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, round)
+	}
+	return rounds, rows.Err()
+}
+
+func (s *Service) ListExpiredClaims(ctx context.Context) ([]Round, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT `+claimRoundColumns+`
+FROM lottery_rounds
+WHERE claim_status = 'pending' AND claim_expires_at <= NOW()
+ORDER BY claim_expires_at
+LIMIT 50
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rounds := make([]Round, 0)
+	for rows.Next() {
+		round, err := scanClaimRound(rows)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, round)
+	}
+	return rounds, rows.Err()
+}
+
+func (s *Service) ClaimPrize(ctx context.Context, roundID, userID int64, deliver PrizeDeliverer) (Round, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Round{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	round, err := scanClaimRound(tx.QueryRow(ctx, `SELECT `+claimRoundColumns+` FROM lottery_rounds WHERE id = $1 FOR UPDATE`, roundID))
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (round.WinnerUserID == nil || *round.WinnerUserID != userID)) {
+		return Round{}, errors.New("prize not found")
+	}
+	if err != nil {
+		return Round{}, err
+	}
+	if round.ClaimStatus != ClaimPending {
+		return Round{}, errors.New("prize is not awaiting a claim")
+	}
+	if round.ClaimExpiresAt != nil && !time.Now().Before(*round.ClaimExpiresAt) {
+		return Round{}, errors.New("claim window has expired")
+	}
+
+	if round.PrizeCents > 0 {
+		if _, err := s.wallet.AdjustBalance(ctx, tx, userID, round.PrizeCents, "stream_giveaway_reward", map[string]interface{}{"trigger_type": round.TriggerType, "lottery_round_id": round.ID}); err != nil {
+			return Round{}, err
+		}
+	}
+	if deliver != nil {
+		itemID, err := deliver(ctx, tx, round)
+		if err != nil {
+			return Round{}, err
+		}
+		if err := MarkPrizeDelivered(ctx, tx, round.ID, itemID); err != nil {
+			return Round{}, err
+		}
+		round.PrizeItemID = &itemID
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE lottery_rounds SET claim_status = 'claimed', claimed_at = NOW() WHERE id = $1`, round.ID); err != nil {
+		return Round{}, err
+	}
+	round.ClaimStatus = ClaimClaimed
+
+	if err := tx.Commit(ctx); err != nil {
+		return Round{}, err
+	}
+	return round, nil
+}
+
+func (s *Service) RedrawExpiredClaim(ctx context.Context, roundID int64, userIDs []int64, opts DrawOptions) (*Round, error) {
+	weighting, err := NormalizeWeighting(opts.Weighting)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := NormalizeWinLimits(opts.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := s.GetRound(ctx, roundID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.claimChainWinners(ctx, roundID)
+	if err != nil {
+		return nil, err
+	}
+	eligible := make([]int64, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !previous[id] {
+			eligible = append(eligible, id)
+		}
+	}
+	candidates, err := s.loadCandidatesByUsers(ctx, eligible, original.StreamSessionID, weighting)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	expired, err := scanClaimRound(tx.QueryRow(ctx, `SELECT `+claimRoundColumns+` FROM lottery_rounds WHERE id = $1 FOR UPDATE`, roundID))
+	if err != nil {
+		return nil, err
+	}
+	if expired.ClaimStatus != ClaimPending || expired.ClaimExpiresAt == nil || time.Now().Before(*expired.ClaimExpiresAt) {
+		return nil, nil
+	}
+	if _, err := tx.Exec(ctx, `UPDATE lottery_rounds SET claim_status = 'expired' WHERE id = $1`, roundID); err != nil {
+		return nil, err
+	}
+
+	candidates, limited, err := applyWinLimits(ctx, tx, expired.StreamSessionID, expired.PrizeCents, limits, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, tx.Commit(ctx)
+	}
+
+	draw, err := s.drawFair(ctx, tx, expired.StreamSessionID, expired.TriggerEvent, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	extraDetails := map[string]interface{}{}
+	_ = json.Unmarshal(expired.Details, &extraDetails)
+	extraDetails["candidates"] = len(candidates)
+	extraDetails["fairness"] = draw.Proof
+	extraDetails["weighting"] = weighting
+	extraDetails["candidate_weights"] = candidateBreakdown(candidates)
+	extraDetails["redraw_of_round_id"] = roundID
+	extraDetails["excluded_previous_winners"] = len(previous)
+	delete(extraDetails, "win_limits")
+	delete(extraDetails, "limited")
+	if limits.active() {
+		extraDetails["win_limits"] = limits
+		extraDetails["limited"] = limited
+	}
+	details, _ := json.Marshal(extraDetails)
+
+	round, err := s.insertRound(ctx, tx, draw, expired.TriggerEvent, expired.CaseID, expired.StreamSessionID, expired.TriggerType, expired.PrizeCents, details)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE lottery_rounds SET redraw_of_round_id = $2 WHERE id = $1`, round.ID, roundID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE lottery_rounds SET redrawn_round_id = $2 WHERE id = $1`, roundID, round.ID); err != nil {
+		return nil, err
+	}
+	round.RedrawOfRoundID = &roundID
+
+	window := opts.ClaimWindow
+	if window <= 0 {
+		window = expired.ClaimExpiresAt.Sub(expired.CreatedAt)
+	}
+	if err := openClaim(ctx, tx, &round, window); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &round, nil
+}
+
+func (s *Service) claimChainWinners(ctx context.Context, roundID int64) (map[int64]bool, error) {
+	rows, err := s.db.Query(ctx, `
+WITH RECURSIVE chain AS (
+    SELECT id, winner_user_id, redraw_of_round_id FROM lottery_rounds WHERE id = $1
+    UNION ALL
+    SELECT lr.id, lr.winner_user_id, lr.redraw_of_round_id
+    FROM lottery_rounds lr
+    JOIN chain c ON lr.id = c.redraw_of_round_id
+)
+SELECT winner_user_id FROM chain WHERE winner_user_id IS NOT NULL
+`, roundID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	winners := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		winners[id] = true
+	}
+	return winners, rows.Err()
+}
+
+func scanClaimRound(row pgx.Row) (Round, error) {
+	var r Round
+	err := row.Scan(
+		&r.ID,
+		&r.TriggerEvent,
+		&r.CaseID,
+		&r.StreamSessionID,
+		&r.WinnerUserID,
+		&r.TriggerType,
+		&r.PrizeCents,
+		&r.Details,
+		&r.PrizeItemID,
+		&r.ClaimStatus,
+		&r.ClaimExpiresAt,
+		&r.RedrawOfRoundID,
+		&r.RedrawnRoundID,
+		&r.CreatedAt,
+	)
+	return r, err
+}
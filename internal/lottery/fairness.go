@@ -0,0 +1,309 @@
+package lottery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/fairness"
+	"github.com/jackc/pgx/v5"
+)
+
+type Seed struct {
+	ID              int64      `json:"id"`
+	StreamSessionID *int64     `json:"stream_session_id,omitempty"`
+	Commitment      string     `json:"commitment"`
+	ServerSeed      string     `json:"server_seed,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	RevealedAt      *time.Time `json:"revealed_at,omitempty"`
+}
+
+type RoundVerification struct {
+	RoundID          int64                `json:"round_id"`
+	SeedID           int64                `json:"seed_id"`
+	Commitment       string               `json:"commitment"`
+	ServerSeed       string               `json:"server_seed,omitempty"`
+	Revealed         bool                 `json:"revealed"`
+	EventID          *int64               `json:"event_id,omitempty"`
+	PublicInput      string               `json:"public_input"`
+	ParticipantsHash string               `json:"participants_hash"`
+	Candidates       []fairness.Candidate `json:"candidates"`
+	Roll             int64                `json:"roll"`
+	WinnerUserID     int64                `json:"winner_user_id"`
+	Verified         bool                 `json:"verified"`
+	Error            string               `json:"error,omitempty"`
+}
+
+type drawProof struct {
+	SeedID           int64                `json:"seed_id"`
+	Commitment       string               `json:"commitment"`
+	EventID          *int64               `json:"event_id,omitempty"`
+	PublicInput      string               `json:"public_input"`
+	ParticipantsHash string               `json:"participants_hash"`
+	Candidates       []fairness.Candidate `json:"candidates"`
+	Roll             int64                `json:"roll"`
+}
+
+type fairDraw struct {
+	RoundID  int64
+	WinnerID int64
+	SeedID   int64
+	Proof    drawProof
+}
+
+func (s *Service) CommitSessionSeed(ctx context.Context, tx pgx.Tx, sessionID int64) (string, error) {
+	seed, err := fairness.NewServerSeed()
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO draw_seeds (stream_session_id, server_seed, commitment)
+VALUES ($1, $2, $3)
+ON CONFLICT (stream_session_id) DO NOTHING
+`, sessionID, seed, fairness.Commit(seed)); err != nil {
+		return "", err
+	}
+
+	var commitment string
+	err = tx.QueryRow(ctx, `SELECT commitment FROM draw_seeds WHERE stream_session_id = $1`, sessionID).Scan(&commitment)
+	return commitment, err
+}
+
+func (s *Service) RevealSessionSeed(ctx context.Context, sessionID int64) error {
+	_, err := s.db.Exec(ctx, `UPDATE draw_seeds SET revealed_at = NOW() WHERE stream_session_id = $1 AND revealed_at IS NULL`, sessionID)
+	return err
+}
+
+func (s *Service) RevealEndedSessionSeeds(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, `
+UPDATE draw_seeds d
+SET revealed_at = NOW()
+FROM stream_sessions ss
+WHERE ss.id = d.stream_session_id
+  AND ss.status IN ('ended', 'cancelled')
+  AND d.revealed_at IS NULL
+`)
+	return err
+}
+
+func (s *Service) RotateGlobalSeed(ctx context.Context, maxAge time.Duration) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+UPDATE draw_seeds
+SET revealed_at = NOW()
+WHERE stream_session_id IS NULL AND revealed_at IS NULL AND created_at < NOW() - make_interval(secs => $1)
+`, maxAge.Seconds()); err != nil {
+		return err
+	}
+	if _, _, _, err := globalSeed(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Service) GetSessionSeed(ctx context.Context, sessionID int64) (Seed, error) {
+	return scanSeed(s.db.QueryRow(ctx, `
+SELECT id, stream_session_id, commitment, server_seed, created_at, revealed_at
+FROM draw_seeds
+WHERE stream_session_id = $1
+`, sessionID))
+}
+
+func (s *Service) CurrentGlobalSeed(ctx context.Context) (Seed, error) {
+	return scanSeed(s.db.QueryRow(ctx, `
+SELECT id, stream_session_id, commitment, server_seed, created_at, revealed_at
+FROM draw_seeds
+WHERE stream_session_id IS NULL AND revealed_at IS NULL
+ORDER BY id DESC
+LIMIT 1
+`))
+}
+
+func (s *Service) VerifyRound(ctx context.Context, roundID int64) (RoundVerification, error) {
+	var winnerID *int64
+	var seedID *int64
+	var details json.RawMessage
+	err := s.db.QueryRow(ctx, `SELECT winner_user_id, seed_id, details FROM lottery_rounds WHERE id = $1`, roundID).Scan(&winnerID, &seedID, &details)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return RoundVerification{}, errors.New("round not found")
+	}
+	if err != nil {
+		return RoundVerification{}, err
+	}
+	if seedID == nil || winnerID == nil {
+		return RoundVerification{}, errors.New("round was drawn before provably fair draws were enabled")
+	}
+
+	var payload struct {
+		Fairness drawProof `json:"fairness"`
+	}
+	if err := json.Unmarshal(details, &payload); err != nil {
+		return RoundVerification{}, err
+	}
+	proof := payload.Fairness
+
+	seed, err := scanSeed(s.db.QueryRow(ctx, `
+SELECT id, stream_session_id, commitment, server_seed, created_at, revealed_at
+FROM draw_seeds
+WHERE id = $1
+`, *seedID))
+	if err != nil {
+		return RoundVerification{}, err
+	}
+
+	result := RoundVerification{
+		RoundID:          roundID,
+		SeedID:           seed.ID,
+		Commitment:       seed.Commitment,
+		Revealed:         seed.RevealedAt != nil,
+		EventID:          proof.EventID,
+		PublicInput:      proof.PublicInput,
+		ParticipantsHash: proof.ParticipantsHash,
+		Candidates:       proof.Candidates,
+		Roll:             proof.Roll,
+		WinnerUserID:     *winnerID,
+	}
+	if !result.Revealed {
+		result.Error = "server seed is revealed when the session ends"
+		return result, nil
+	}
+	result.ServerSeed = seed.ServerSeed
+
+	err = fairness.Verify(fairness.Proof{
+		ServerSeed:       seed.ServerSeed,
+		Commitment:       seed.Commitment,
+		RoundID:          roundID,
+		EventID:          proof.EventID,
+		ParticipantsHash: proof.ParticipantsHash,
+		Candidates:       proof.Candidates,
+		WinnerUserID:     *winnerID,
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Verified = true
+	return result, nil
+}
+
+func (s *Service) drawFair(ctx context.Context, tx pgx.Tx, streamSessionID, triggerEventID *int64, candidates []weightedUser) (fairDraw, error) {
+	seedID, serverSeed, commitment, err := drawSeed(ctx, tx, streamSessionID)
+	if err != nil {
+		return fairDraw{}, err
+	}
+
+	var roundID int64
+	if err := tx.QueryRow(ctx, `SELECT nextval(pg_get_serial_sequence('lottery_rounds', 'id'))`).Scan(&roundID); err != nil {
+		return fairDraw{}, err
+	}
+
+	fc := make([]fairness.Candidate, 0, len(candidates))
+	for _, c := range candidates {
+		fc = append(fc, fairness.Candidate{UserID: c.UserID, Weight: c.Weight})
+	}
+	fc = fairness.Canonical(fc)
+	hash := fairness.HashParticipants(fc)
+	input := fairness.PublicInput(roundID, triggerEventID, hash)
+
+	winnerID, roll, err := fairness.Draw(serverSeed, input, fc)
+	if err != nil {
+		return fairDraw{}, err
+	}
+
+	return fairDraw{
+		RoundID:  roundID,
+		WinnerID: winnerID,
+		SeedID:   seedID,
+		Proof: drawProof{
+			SeedID:           seedID,
+			Commitment:       commitment,
+			EventID:          triggerEventID,
+			PublicInput:      input,
+			ParticipantsHash: hash,
+			Candidates:       fc,
+			Roll:             roll,
+		},
+	}, nil
+}
+
+func drawSeed(ctx context.Context, tx pgx.Tx, streamSessionID *int64) (int64, string, string, error) {
+	if streamSessionID != nil {
+		var id int64
+		var seed, commitment string
+		var revealed bool
+		err := tx.QueryRow(ctx, `
+SELECT id, server_seed, commitment, revealed_at IS NOT NULL
+FROM draw_seeds
+WHERE stream_session_id = $1
+`, *streamSessionID).Scan(&id, &seed, &commitment, &revealed)
+		switch {
+		case err == nil && !revealed:
+			return id, seed, commitment, nil
+		case errors.Is(err, pgx.ErrNoRows):
+			serverSeed, err := fairness.NewServerSeed()
+			if err != nil {
+				return 0, "", "", err
+			}
+			err = tx.QueryRow(ctx, `
+INSERT INTO draw_seeds (stream_session_id, server_seed, commitment)
+VALUES ($1, $2, $3)
+RETURNING id
+`, *streamSessionID, serverSeed, fairness.Commit(serverSeed)).Scan(&id)
+			return id, serverSeed, fairness.Commit(serverSeed), err
+		case err != nil:
+			return 0, "", "", err
+		}
+	}
+	return globalSeed(ctx, tx)
+}
+
+func globalSeed(ctx context.Context, tx pgx.Tx) (int64, string, string, error) {
+	var id int64
+	var seed, commitment string
+	err := tx.QueryRow(ctx, `
+SELECT id, server_seed, commitment
+FROM draw_seeds
+WHERE stream_session_id IS NULL AND revealed_at IS NULL
+ORDER BY id DESC
+LIMIT 1
+`).Scan(&id, &seed, &commitment)
+	if err == nil {
+		return id, seed, commitment, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, "", "", err
+	}
+
+	seed, err = fairness.NewServerSeed()
+	if err != nil {
+		return 0, "", "", err
+	}
+	commitment = fairness.Commit(seed)
+	err = tx.QueryRow(ctx, `
+INSERT INTO draw_seeds (server_seed, commitment)
+VALUES ($1, $2)
+RETURNING id
+`, seed, commitment).Scan(&id)
+	return id, seed, commitment, err
+}
+
+func scanSeed(row pgx.Row) (Seed, error) {
+	var seed Seed
+	err := row.Scan(&seed.ID, &seed.StreamSessionID, &seed.Commitment, &seed.ServerSeed, &seed.CreatedAt, &seed.RevealedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Seed{}, errors.New("seed not found")
+	}
+	if err != nil {
+		return Seed{}, err
+	}
+	if seed.RevealedAt == nil {
+		seed.ServerSeed = ""
+	}
+	return seed, nil
+}
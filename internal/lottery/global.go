@@ -0,0 +1,219 @@
+package lottery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	FundingPool     = "pool"
+	FundingPlatform = "platform"
+
+	ScopeStream   = "stream"
+	ScopePlatform = "platform"
+)
+
+type GlobalSettings struct {
+	Enabled             bool      `json:"enabled"`
+	TriggerTypes        []string  `json:"trigger_types"`
+	PrizeCents          int64     `json:"prize_cents"`
+	FundingSource       string    `json:"funding_source"`
+	PoolCents           int64     `json:"pool_cents"`
+	Scope               string    `json:"scope"`
+	ActivityWindowHours int       `json:"activity_window_hours"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+func (s *Service) GetGlobalSettings(ctx context.Context) (GlobalSettings, error) {
+	var g GlobalSettings
+	err := s.db.QueryRow(ctx, `
+SELECT enabled, trigger_types, prize_cents, funding_source, pool_cents, scope, activity_window_hours, updated_at
+FROM global_lottery_settings
+WHERE id = 1
+`).Scan(&g.Enabled, &g.TriggerTypes, &g.PrizeCents, &g.FundingSource, &g.PoolCents, &g.Scope, &g.ActivityWindowHours, &g.UpdatedAt)
+	return g, err
+}
+
+func (s *Service) UpdateGlobalSettings(ctx context.Context, g GlobalSettings) (GlobalSettings, error) {
+	triggers := make([]string, 0, len(g.TriggerTypes))
+	seen := make(map[string]bool)
+	for _, t := range g.TriggerTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			triggers = append(triggers, t)
+		}
+	}
+	if g.Enabled && len(triggers) == 0 {
+		return GlobalSettings{}, errors.New("trigger_types is required when the global lottery is enabled")
+	}
+	if g.PrizeCents < 0 {
+		return GlobalSettings{}, errors.New("prize_cents cannot be negative")
+	}
+	g.FundingSource = strings.ToLower(strings.TrimSpace(g.FundingSource))
+	if g.FundingSource != FundingPool && g.FundingSource != FundingPlatform {
+		return GlobalSettings{}, errors.New("funding_source must be pool or platform")
+	}
+	g.Scope = strings.ToLower(strings.TrimSpace(g.Scope))
+	if g.Scope != ScopeStream && g.Scope != ScopePlatform {
+		return GlobalSettings{}, errors.New("scope must be stream or platform")
+	}
+	if g.ActivityWindowHours <= 0 {
+		g.ActivityWindowHours = 24
+	}
+
+	var out GlobalSettings
+	err := s.db.QueryRow(ctx, `
+UPDATE global_lottery_settings
+SET enabled = $1, trigger_types = $2, prize_cents = $3, funding_source = $4, scope = $5, activity_window_hours = $6, updated_at = NOW()
+WHERE id = 1
+RETURNING enabled, trigger_types, prize_cents, funding_source, pool_cents, scope, activity_window_hours, updated_at
+`, g.Enabled, triggers, g.PrizeCents, g.FundingSource, g.Scope, g.ActivityWindowHours).Scan(&out.Enabled, &out.TriggerTypes, &out.PrizeCents, &out.FundingSource, &out.PoolCents, &out.Scope, &out.ActivityWindowHours, &out.UpdatedAt)
+	return out, err
+}
+
+func (s *Service) FundGlobalPool(ctx context.Context, amountCents int64) (int64, error) {
+	if amountCents <= 0 {
+		return 0, errors.New("amount_cents must be positive")
+	}
+	var pool int64
+	err := s.db.QueryRow(ctx, `
+UPDATE global_lottery_settings
+SET pool_cents = pool_cents + $1, updated_at = NOW()
+WHERE id = 1
+RETURNING pool_cents
+`, amountCents).Scan(&pool)
+	return pool, err
+}
+
+func (s *Service) TriggerGlobal(ctx context.Context, triggerType string, triggerEventID, streamerID *int64) (*Round, error) {
+	settings, err := s.GetGlobalSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled || !settings.triggers(triggerType) {
+		return nil, nil
+	}
+
+	userIDs, err := s.globalEligibleUsers(ctx, settings, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	weighting, _ := NormalizeWeighting(Weighting{})
+	candidates, err := s.loadCandidatesByUsers(ctx, userIDs, nil, weighting)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if settings.FundingSource == FundingPool && settings.PrizeCents > 0 {
+		result, err := tx.Exec(ctx, `
+UPDATE global_lottery_settings
+SET pool_cents = pool_cents - $1
+WHERE id = 1 AND pool_cents >= $1
+`, settings.PrizeCents)
+		if err != nil {
+			return nil, err
+		}
+		if result.RowsAffected() == 0 {
+			return nil, nil
+		}
+	}
+
+	draw, err := s.drawFair(ctx, tx, nil, triggerEventID, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.PrizeCents > 0 {
+		if _, err := s.wallet.AdjustBalance(ctx, tx, draw.WinnerID, settings.PrizeCents, "lottery_reward", map[string]interface{}{"trigger_type": triggerType, "funding_source": settings.FundingSource}); err != nil {
+			return nil, err
+		}
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"lottery":           "global",
+		"scope":             settings.Scope,
+		"streamer_id":       streamerID,
+		"funding_source":    settings.FundingSource,
+		"candidates":        len(candidates),
+		"weighting":         weighting,
+		"candidate_weights": candidateBreakdown(candidates),
+		"fairness":          draw.Proof,
+	})
+	round, err := s.insertRound(ctx, tx, draw, triggerEventID, nil, nil, triggerType, settings.PrizeCents, details)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &round, nil
+}
+
+func (g GlobalSettings) triggers(triggerType string) bool {
+	for _, t := range g.TriggerTypes {
+		if t == triggerType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) globalEligibleUsers(ctx context.Context, settings GlobalSettings, streamerID *int64) ([]int64, error) {
+	var query string
+	switch settings.Scope {
+	case ScopeStream:
+		if streamerID == nil {
+			return nil, nil
+		}
+		query = `
+SELECT sp.user_id
+FROM stream_sessions ss
+JOIN stream_participants sp ON sp.stream_session_id = ss.id
+WHERE ss.streamer_id = $1
+  AND ss.status = 'active'
+  AND sp.last_seen_at > NOW() - make_interval(hours => $2)
+  AND NOT EXISTS (
+      SELECT 1 FROM stream_bans b
+      WHERE b.streamer_id = ss.streamer_id AND b.user_id = sp.user_id
+        AND (b.stream_session_id IS NULL OR b.stream_session_id = ss.id)
+  )
+`
+	default:
+		query = `
+SELECT va.user_id
+FROM viewer_activity va
+WHERE va.updated_at > NOW() - make_interval(hours => $2)
+  AND va.user_id IS DISTINCT FROM $1::bigint
+`
+	}
+
+	rows, err := s.db.Query(ctx, query, streamerID, settings.ActivityWindowHours)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
@@ -2,11 +2,13 @@ package lottery
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/auth"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/httpx"
+	"github.com/go-chi/chi/v5"
 )
 
 type Handler struct {
@@ -17,8 +19,14 @@ func NewHandler(svc *Service) *Handler {
 	return &Handler{svc: svc}
 }
 
-type joinRequest struct {
-	ScoreDelta int64 `json:"score_delta"`
+type fundRequest struct {
+	AmountCents int64 `json:"amount_cents"`
+}
+
+type activityTypeRequest struct {
+	Points          int64 `json:"points"`
+	CooldownSeconds int   `json:"cooldown_seconds"`
+	Enabled         bool  `json:"enabled"`
 }
 
 func (h *Handler) Join(w http.ResponseWriter, r *http.Request) {
@@ -28,13 +36,7 @@ func (h *Handler) Join(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req joinRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httpx.Error(w, http.StatusBadRequest, "invalid json body")
-		return
-	}
-
-	if err := h.svc.Join(r.Context(), user.ID, req.ScoreDelta); err != nil {
+	if err := h.svc.Join(r.Context(), user.ID); err != nil {
 		httpx.Error(w, http.StatusInternalServerError, "failed to join lottery")
 		return
 	}
@@ -43,18 +45,200 @@ func (h *Handler) Join(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListRounds(w http.ResponseWriter, r *http.Request) {
-	limit := 25
-	if raw := r.URL.Query().Get("limit"); raw != "" {
+	filter, err := parseRoundFilter(r)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rounds, next, err := h.svc.ListRounds(r.Context(), filter)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list rounds")
+		return
+	}
+
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"rounds": rounds, "next_before": next})
+}
+
+func (h *Handler) MyRounds(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	filter, err := parseRoundFilter(r)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ParticipantUserID = &user.ID
+	if r.URL.Query().Get("won") == "true" {
+		filter.WinnerUserID = &user.ID
+	}
+
+	rounds, next, err := h.svc.ListRounds(r.Context(), filter)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list rounds")
+		return
+	}
+
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"rounds": rounds, "next_before": next})
+}
+
+func parseRoundFilter(r *http.Request) (RoundFilter, error) {
+	q := r.URL.Query()
+	filter := RoundFilter{TriggerType: q.Get("trigger_type")}
+	if raw := q.Get("limit"); raw != "" {
 		if parsed, err := strconv.Atoi(raw); err == nil {
-			limit = parsed
+			filter.Limit = parsed
 		}
 	}
 
-	rounds, err := h.svc.ListRounds(r.Context(), limit)
+	ids := []struct {
+		name string
+		dst  **int64
+	}{
+		{"session_id", &filter.StreamSessionID},
+		{"case_id", &filter.CaseID},
+		{"winner_id", &filter.WinnerUserID},
+		{"before", &filter.BeforeID},
+	}
+	for _, id := range ids {
+		raw := q.Get(id.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return RoundFilter{}, errors.New("invalid " + id.name)
+		}
+		*id.dst = &parsed
+	}
+	return filter, nil
+}
+
+func (h *Handler) VerifyRound(w http.ResponseWriter, r *http.Request) {
+	roundID, err := strconv.ParseInt(chi.URLParam(r, "roundID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid round id")
+		return
+	}
+	verification, err := h.svc.VerifyRound(r.Context(), roundID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"verification": verification})
+}
+
+func (h *Handler) SessionSeed(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
 	if err != nil {
-		httpx.Error(w, http.StatusInternalServerError, "failed to list rounds")
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	seed, err := h.svc.GetSessionSeed(r.Context(), sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"seed": seed})
+}
+
+func (h *Handler) CurrentGlobalSeed(w http.ResponseWriter, r *http.Request) {
+	seed, err := h.svc.CurrentGlobalSeed(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"seed": seed})
+}
+
+func (h *Handler) GlobalSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.svc.GetGlobalSettings(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to load global lottery settings")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"settings": settings})
+}
+
+func (h *Handler) UpdateGlobalSettings(w http.ResponseWriter, r *http.Request) {
+	var req GlobalSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	settings, err := h.svc.UpdateGlobalSettings(r.Context(), req)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"settings": settings})
+}
+
+func (h *Handler) FundGlobalPool(w http.ResponseWriter, r *http.Request) {
+	var req fundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	pool, err := h.svc.FundGlobalPool(r.Context(), req.AmountCents)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"pool_cents": pool})
+}
+
+func (h *Handler) ListActivityTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := h.svc.ListActivityTypes(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list activity types")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"activity_types": types})
+}
+
+func (h *Handler) UpdateActivityType(w http.ResponseWriter, r *http.Request) {
+	var req activityTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	activityType, err := h.svc.UpdateActivityType(r.Context(), chi.URLParam(r, "key"), req.Points, req.CooldownSeconds, req.Enabled)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"activity_type": activityType})
+}
+
+func (h *Handler) MyActivity(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var sessionID *int64
+	if raw := r.URL.Query().Get("session_id"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, "invalid session id")
+			return
+		}
+		sessionID = &parsed
+	}
 
-	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"rounds": rounds})
+	explanation, err := h.svc.ExplainActivity(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to load activity")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"activity": explanation})
 }
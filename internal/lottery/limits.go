@@ -0,0 +1,115 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	LimitExclude    = "exclude"
+	LimitDownWeight = "down_weight"
+)
+
+const downWeightDivisor = 10
+
+type WinLimits struct {
+	MaxWinsPerHour    int    `json:"max_wins_per_hour"`
+	MaxWinsPerSession int    `json:"max_wins_per_session"`
+	MaxWinValueCents  int64  `json:"max_win_value_cents"`
+	Mode              string `json:"mode"`
+}
+
+type LimitedCandidate struct {
+	UserID int64  `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+func NormalizeWinLimits(l WinLimits) (WinLimits, error) {
+	if l.MaxWinsPerHour < 0 || l.MaxWinsPerSession < 0 || l.MaxWinValueCents < 0 {
+		return WinLimits{}, errors.New("win limits cannot be negative")
+	}
+	l.Mode = strings.ToLower(strings.TrimSpace(l.Mode))
+	if l.Mode == "" {
+		l.Mode = LimitExclude
+	}
+	if l.Mode != LimitExclude && l.Mode != LimitDownWeight {
+		return WinLimits{}, errors.New("win limit mode must be exclude or down_weight")
+	}
+	return l, nil
+}
+
+func (l WinLimits) active() bool {
+	return l.MaxWinsPerHour > 0 || l.MaxWinsPerSession > 0 || l.MaxWinValueCents > 0
+}
+
+func applyWinLimits(ctx context.Context, tx pgx.Tx, streamSessionID *int64, prizeCents int64, limits WinLimits, candidates []weightedUser) ([]weightedUser, []LimitedCandidate, error) {
+	if !limits.active() || len(candidates) == 0 {
+		return candidates, nil, nil
+	}
+	if streamSessionID != nil {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM stream_sessions WHERE id = $1 FOR UPDATE`, *streamSessionID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	userIDs := make([]int64, 0, len(candidates))
+	for _, c := range candidates {
+		userIDs = append(userIDs, c.UserID)
+	}
+	rows, err := tx.Query(ctx, `
+SELECT u.id,
+       COUNT(lr.id) FILTER (WHERE lr.stream_session_id IS NOT DISTINCT FROM $2::bigint AND lr.created_at > NOW() - INTERVAL '1 hour'),
+       COUNT(lr.id) FILTER (WHERE lr.stream_session_id IS NOT DISTINCT FROM $2::bigint),
+       COALESCE(SUM(lr.prize_cents), 0)
+FROM unnest($1::bigint[]) AS u(id)
+LEFT JOIN lottery_rounds lr ON lr.winner_user_id = u.id AND lr.claim_status IS DISTINCT FROM 'expired'
+GROUP BY u.id
+`, userIDs, streamSessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	reasons := make(map[int64]string)
+	for rows.Next() {
+		var userID, hourWins, sessionWins, wonCents int64
+		if err := rows.Scan(&userID, &hourWins, &sessionWins, &wonCents); err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case limits.MaxWinsPerHour > 0 && hourWins >= int64(limits.MaxWinsPerHour):
+			reasons[userID] = fmt.Sprintf("won %d times in the last hour (limit %d)", hourWins, limits.MaxWinsPerHour)
+		case limits.MaxWinsPerSession > 0 && sessionWins >= int64(limits.MaxWinsPerSession):
+			reasons[userID] = fmt.Sprintf("won %d times this session (limit %d)", sessionWins, limits.MaxWinsPerSession)
+		case limits.MaxWinValueCents > 0 && wonCents+prizeCents > limits.MaxWinValueCents:
+			reasons[userID] = fmt.Sprintf("already won %d cents in total, prize would exceed the %d cent lifetime cap", wonCents, limits.MaxWinValueCents)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	kept := make([]weightedUser, 0, len(candidates))
+	limited := make([]LimitedCandidate, 0, len(reasons))
+	for _, c := range candidates {
+		reason, ok := reasons[c.UserID]
+		if !ok {
+			kept = append(kept, c)
+			continue
+		}
+		limited = append(limited, LimitedCandidate{UserID: c.UserID, Reason: reason})
+		if limits.Mode == LimitDownWeight {
+			c.Weight /= downWeightDivisor
+			if c.Weight < 1 {
+				c.Weight = 1
+			}
+			c.Limited = reason
+			kept = append(kept, c)
+		}
+	}
+	return kept, limited, nil
+}
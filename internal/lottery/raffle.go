@@ -0,0 +1,96 @@
+package lottery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+func (s *Service) DrawForRaffle(ctx context.Context, raffleID int64, streamSessionID *int64, prizeCents int64, extraDetails map[string]interface{}, deliver PrizeDeliverer) (*Round, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	if err := tx.QueryRow(ctx, `SELECT status FROM raffles WHERE id = $1 FOR UPDATE`, raffleID).Scan(&status); err != nil {
+		return nil, err
+	}
+	if status != "open" {
+		return nil, errors.New("raffle is not open")
+	}
+
+	rows, err := tx.Query(ctx, `
+SELECT user_id, SUM(quantity) AS weight
+FROM raffle_tickets
+WHERE raffle_id = $1 AND refunded_at IS NULL
+GROUP BY user_id
+`, raffleID)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]weightedUser, 0)
+	for rows.Next() {
+		var c weightedUser
+		if err := rows.Scan(&c.UserID, &c.Weight); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		if _, err := tx.Exec(ctx, `UPDATE raffles SET status = 'no_entries', updated_at = NOW() WHERE id = $1`, raffleID); err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit(ctx)
+	}
+
+	draw, err := s.drawFair(ctx, tx, streamSessionID, nil, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	if extraDetails == nil {
+		extraDetails = map[string]interface{}{}
+	}
+	extraDetails["raffle_id"] = raffleID
+	extraDetails["candidates"] = len(candidates)
+	extraDetails["stream_session_id"] = streamSessionID
+	extraDetails["fairness"] = draw.Proof
+	details, _ := json.Marshal(extraDetails)
+
+	round, err := s.insertRound(ctx, tx, draw, nil, nil, streamSessionID, "raffle", prizeCents, details)
+	if err != nil {
+		return nil, err
+	}
+
+	if deliver != nil {
+		itemID, err := deliver(ctx, tx, round)
+		if err != nil {
+			return nil, err
+		}
+		if err := MarkPrizeDelivered(ctx, tx, round.ID, itemID); err != nil {
+			return nil, err
+		}
+		round.PrizeItemID = &itemID
+	}
+
+	if _, err := tx.Exec(ctx, `
+UPDATE raffles
+SET status = 'drawn', lottery_round_id = $2, winner_user_id = $3, updated_at = NOW()
+WHERE id = $1
+`, raffleID, round.ID, draw.WinnerID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &round, nil
+}
@@ -2,10 +2,8 @@ package lottery
 
 import (
 	"context"
-	"crypto/rand"
 	"encoding/json"
 	"errors"
-	"math/big"
 	"time"
 
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/wallet"
@@ -14,8 +12,9 @@ import (
 )
 
 type Service struct {
-	db     *pgxpool.Pool
-	wallet *wallet.Service
+	db               *pgxpool.Pool
+	wallet           *wallet.Service
+	activityHalfLife time.Duration
 }
 
 type Round struct {
@@ -24,79 +23,67 @@ type Round struct {
 	CaseID          *int64          `json:"case_id,omitempty"`
 	StreamSessionID *int64          `json:"stream_session_id,omitempty"`
 	WinnerUserID    *int64          `json:"winner_user_id,omitempty"`
+	WinnerName      string          `json:"winner_name,omitempty"`
 	TriggerType     string          `json:"trigger_type"`
 	PrizeCents      int64           `json:"prize_cents"`
 	Details         json.RawMessage `json:"details"`
+	PrizeItemID     *int64          `json:"prize_item_id,omitempty"`
+	ClaimStatus     string          `json:"claim_status,omitempty"`
+	ClaimExpiresAt  *time.Time      `json:"claim_expires_at,omitempty"`
+	RedrawOfRoundID *int64          `json:"redraw_of_round_id,omitempty"`
+	RedrawnRoundID  *int64          `json:"redrawn_round_id,omitempty"`
 	CreatedAt       time.Time       `json:"created_at"`
 }
 
-type weightedUser struct {
-	UserID int64
-	Weight int64
+type RoundFilter struct {
+	StreamSessionID   *int64
+	CaseID            *int64
+	TriggerType       string
+	WinnerUserID      *int64
+	ParticipantUserID *int64
+	BeforeID          *int64
+	Limit             int
 }
 
-func NewService(db *pgxpool.Pool, wallet *wallet.Service) *Service {
-	return &Service{db: db, wallet: wallet}
-}
+type PrizeDeliverer func(ctx context.Context, tx pgx.Tx, round Round) (int64, error)
 
-func (s *Service) Join(ctx context.Context, userID int64, scoreDelta int64) error {
-	if scoreDelta <= 0 {
-		scoreDelta = 1
-	}
-	_, err := s.db.Exec(ctx, `
-INSERT INTO viewer_activity (user_id, score, updated_at)
-VALUES ($1, $2, NOW())
-ON CONFLICT (user_id)
-DO UPDATE SET score = viewer_activity.score + EXCLUDED.score, updated_at = NOW()
-`, userID, scoreDelta)
-	return err
+type weightedUser struct {
+	UserID            int64
+	Weight            int64
+	ActivityScore     int64
+	ContributionCents int64
+	RecentWins        int64
+	Limited           string
 }
 
-func (s *Service) RecordActivity(ctx context.Context, userID int64, scoreDelta int64) error {
-	return s.Join(ctx, userID, scoreDelta)
+func NewService(db *pgxpool.Pool, wallet *wallet.Service, activityHalfLife time.Duration) *Service {
+	return &Service{db: db, wallet: wallet, activityHalfLife: activityHalfLife}
 }
 
-func (s *Service) TriggerFromGameEvent(ctx context.Context, triggerType string, triggerEventID *int64, prizeCents int64) (*Round, error) {
-	candidates, err := s.loadCandidates(ctx)
+func (s *Service) TriggerForUsers(ctx context.Context, triggerType string, triggerEventID, streamSessionID *int64, prizeCents int64, userIDs []int64, extraDetails map[string]interface{}, opts DrawOptions, deliver PrizeDeliverer) (*Round, error) {
+	weighting, err := NormalizeWeighting(opts.Weighting)
 	if err != nil {
 		return nil, err
 	}
-	if len(candidates) == 0 {
-		return nil, nil
-	}
-
-	winnerID, err := chooseWinner(candidates)
+	limits, err := NormalizeWinLimits(opts.Limits)
 	if err != nil {
 		return nil, err
 	}
-
-	tx, err := s.db.Begin(ctx)
+	candidates, err := s.loadCandidatesByUsers(ctx, userIDs, streamSessionID, weighting)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback(ctx)
-
-	if prizeCents > 0 {
-		if _, err := s.wallet.AdjustBalance(ctx, tx, winnerID, prizeCents, "lottery_reward", map[string]interface{}{"trigger_type": triggerType}); err != nil {
-			return nil, err
-		}
+	if len(candidates) == 0 {
+		return nil, nil
 	}
 
-	details, _ := json.Marshal(map[string]interface{}{"candidates": len(candidates)})
-	round, err := s.insertRound(ctx, tx, triggerEventID, nil, nil, &winnerID, triggerType, prizeCents, details)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, err
-	}
-
-	return &round, nil
-}
-
-func (s *Service) TriggerForUsers(ctx context.Context, triggerType string, triggerEventID, streamSessionID *int64, prizeCents int64, userIDs []int64, extraDetails map[string]interface{}) (*Round, error) {
-	candidates, err := s.loadCandidatesByUsers(ctx, userIDs)
+	candidates, limited, err := applyWinLimits(ctx, tx, streamSessionID, prizeCents, limits, candidates)
 	if err != nil {
 		return nil, err
 	}
@@ -104,18 +91,13 @@ func (s *Service) TriggerForUsers(ctx context.Context, triggerType string, trigg
 		return nil, nil
 	}
 
-	winnerID, err := chooseWinner(candidates)
-	if err != nil {
-		return nil, err
-	}
-
-	tx, err := s.db.Begin(ctx)
+	draw, err := s.drawFair(ctx, tx, streamSessionID, triggerEventID, candidates)
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback(ctx)
+	winnerID := draw.WinnerID
 
-	if prizeCents > 0 {
+	if prizeCents > 0 && opts.ClaimWindow <= 0 {
 		if _, err := s.wallet.AdjustBalance(ctx, tx, winnerID, prizeCents, "stream_giveaway_reward", map[string]interface{}{"trigger_type": triggerType}); err != nil {
 			return nil, err
 		}
@@ -126,19 +108,49 @@ func (s *Service) TriggerForUsers(ctx context.Context, triggerType string, trigg
 	}
 	extraDetails["candidates"] = len(candidates)
 	extraDetails["stream_session_id"] = streamSessionID
+	extraDetails["fairness"] = draw.Proof
+	extraDetails["weighting"] = weighting
+	extraDetails["candidate_weights"] = candidateBreakdown(candidates)
+	if limits.active() {
+		extraDetails["win_limits"] = limits
+		extraDetails["limited"] = limited
+	}
+	if opts.ClaimWindow > 0 {
+		extraDetails["claim_window_seconds"] = int64(opts.ClaimWindow.Seconds())
+	}
 	details, _ := json.Marshal(extraDetails)
 
-	round, err := s.insertRound(ctx, tx, triggerEventID, nil, streamSessionID, &winnerID, triggerType, prizeCents, details)
+	round, err := s.insertRound(ctx, tx, draw, triggerEventID, nil, streamSessionID, triggerType, prizeCents, details)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.ClaimWindow > 0 {
+		if err := openClaim(ctx, tx, &round, opts.ClaimWindow); err != nil {
+			return nil, err
+		}
+	} else if deliver != nil {
+		itemID, err := deliver(ctx, tx, round)
+		if err != nil {
+			return nil, err
+		}
+		if err := MarkPrizeDelivered(ctx, tx, round.ID, itemID); err != nil {
+			return nil, err
+		}
+		round.PrizeItemID = &itemID
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
 	return &round, nil
 }
 
+func MarkPrizeDelivered(ctx context.Context, tx pgx.Tx, roundID, itemID int64) error {
+	_, err := tx.Exec(ctx, `UPDATE lottery_rounds SET prize_item_id = $2, prize_delivered_at = NOW() WHERE id = $1`, roundID, itemID)
+	return err
+}
+
 func (s *Service) DrawForCase(ctx context.Context, caseID int64, potCents int64, streamSessionID *int64) (Round, error) {
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
@@ -171,7 +183,7 @@ GROUP BY user_id
 		return Round{}, errors.New("no contributors to draw from")
 	}
 
-	winnerID, err := chooseWinner(candidates)
+	draw, err := s.drawFair(ctx, tx, streamSessionID, nil, candidates)
 	if err != nil {
 		return Round{}, err
 	}
@@ -180,8 +192,8 @@ GROUP BY user_id
 		return Round{}, err
 	}
 
-	details, _ := json.Marshal(map[string]interface{}{"draw": "crowdfunding_case", "contributors": len(candidates)})
-	round, err := s.insertRound(ctx, tx, nil, &caseID, streamSessionID, &winnerID, "case_funded", potCents, details)
+	details, _ := json.Marshal(map[string]interface{}{"draw": "crowdfunding_case", "contributors": len(candidates), "fairness": draw.Proof})
+	round, err := s.insertRound(ctx, tx, draw, nil, &caseID, streamSessionID, "case_funded", potCents, details)
 	if err != nil {
 		return Round{}, err
 	}
@@ -193,74 +205,79 @@ GROUP BY user_id
 	return round, nil
 }
 
-func (s *Service) ListRounds(ctx context.Context, limit int) ([]Round, error) {
-	if limit <= 0 || limit > 100 {
-		limit = 25
+func (s *Service) ListRounds(ctx context.Context, filter RoundFilter) ([]Round, *int64, error) {
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 25
 	}
 
 	rows, err := s.db.Query(ctx, `
-SELECT id, trigger_event_id, case_id, stream_session_id, winner_user_id, trigger_type, prize_cents, details, created_at
-FROM lottery_rounds
-ORDER BY created_at DESC
-LIMIT $1
-`, limit)
+SELECT lr.id, lr.trigger_event_id, lr.case_id, lr.stream_session_id, lr.winner_user_id, COALESCE(u.username, u.telegram_username, ''), lr.trigger_type, lr.prize_cents, lr.details, lr.prize_item_id,
+       COALESCE(lr.claim_status, ''), lr.claim_expires_at, lr.redraw_of_round_id, lr.redrawn_round_id, lr.created_at
+FROM lottery_rounds lr
+LEFT JOIN users u ON u.id = lr.winner_user_id
+WHERE ($1::bigint IS NULL OR lr.stream_session_id = $1)
+  AND ($2::bigint IS NULL OR lr.case_id = $2)
+  AND ($3 = '' OR lr.trigger_type = $3)
+  AND ($4::bigint IS NULL OR lr.winner_user_id = $4)
+  AND ($5::bigint IS NULL
+       OR lr.winner_user_id = $5
+       OR lr.details->'fairness'->'candidates' @> jsonb_build_array(jsonb_build_object('user_id', $5::bigint)))
+  AND ($6::bigint IS NULL OR lr.id < $6)
+ORDER BY lr.id DESC
+LIMIT $7
+`, filter.StreamSessionID, filter.CaseID, filter.TriggerType, filter.WinnerUserID, filter.ParticipantUserID, filter.BeforeID, filter.Limit+1)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
 	rounds := make([]Round, 0)
 	for rows.Next() {
 		var r Round
-		if err := rows.Scan(&r.ID, &r.TriggerEvent, &r.CaseID, &r.StreamSessionID, &r.WinnerUserID, &r.TriggerType, &r.PrizeCents, &r.Details, &r.CreatedAt); err != nil {
-			return nil, err
+		if err := rows.Scan(&r.ID, &r.TriggerEvent, &r.CaseID, &r.StreamSessionID, &r.WinnerUserID, &r.WinnerName, &r.TriggerType, &r.PrizeCents, &r.Details, &r.PrizeItemID, &r.ClaimStatus, &r.ClaimExpiresAt, &r.RedrawOfRoundID, &r.RedrawnRoundID, &r.CreatedAt); err != nil {
+			return nil, nil, err
 		}
 		rounds = append(rounds, r)
 	}
-	return rounds, rows.Err()
-}
-
-func (s *Service) loadCandidates(ctx context.Context) ([]weightedUser, error) {
-	rows, err := s.db.Query(ctx, `
-SELECT va.user_id, GREATEST(1, va.score + COALESCE(SUM(cc.amount_cents) / 100, 0)) AS weight
-FROM viewer_activity va
-LEFT JOIN case_contributions cc ON cc.user_id = va.user_id
-WHERE va.updated_at > NOW() - INTERVAL '24 hours'
-GROUP BY va.user_id, va.score
-`)
-	if err != nil {
-		return nil, err
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
-	defer rows.Close()
 
-	candidates := make([]weightedUser, 0)
-	for rows.Next() {
-		var c weightedUser
-		if err := rows.Scan(&c.UserID, &c.Weight); err != nil {
-			return nil, err
-		}
-		if c.Weight > 0 {
-			candidates = append(candidates, c)
-		}
+	var next *int64
+	if len(rounds) > filter.Limit {
+		rounds = rounds[:filter.Limit]
+		next = &rounds[len(rounds)-1].ID
 	}
-	return candidates, rows.Err()
+	return rounds, next, nil
 }
 
-func (s *Service) loadCandidatesByUsers(ctx context.Context, userIDs []int64) ([]weightedUser, error) {
+func (s *Service) loadCandidatesByUsers(ctx context.Context, userIDs []int64, streamSessionID *int64, weighting Weighting) ([]weightedUser, error) {
 	if len(userIDs) == 0 {
 		return nil, nil
 	}
 	rows, err := s.db.Query(ctx, `
-SELECT u.id, GREATEST(1, COALESCE(va.score, 0) + COALESCE(contrib.total / 100, 0)) AS weight
+SELECT u.id, COALESCE(FLOOR(va.score), 0)::bigint, COALESCE(contrib.total, 0), COALESCE(wins.total, 0)
 FROM users u
-LEFT JOIN viewer_activity va ON va.user_id = u.id
+LEFT JOIN (
+    SELECT e.user_id, SUM(`+decayedPointsSQL+`) AS score
+    FROM viewer_activity_events e
+    WHERE e.user_id = ANY($1) AND ($3::bigint IS NULL OR e.stream_session_id = $3)
+    GROUP BY e.user_id
+) va ON va.user_id = u.id
 LEFT JOIN (
     SELECT user_id, SUM(amount_cents) AS total
     FROM case_contributions
     GROUP BY user_id
 ) contrib ON contrib.user_id = u.id
+LEFT JOIN (
+    SELECT winner_user_id, COUNT(*) AS total
+    FROM lottery_rounds
+    WHERE created_at > NOW() - INTERVAL '24 hours' AND claim_status IS DISTINCT FROM 'expired'
+      AND ($3::bigint IS NULL OR stream_session_id = $3)
+    GROUP BY winner_user_id
+) wins ON wins.winner_user_id = u.id
 WHERE u.id = ANY($1)
-`, userIDs)
+`, userIDs, s.activityHalfLife.Seconds(), streamSessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -269,23 +286,22 @@ WHERE u.id = ANY($1)
 	candidates := make([]weightedUser, 0)
 	for rows.Next() {
 		var c weightedUser
-		if err := rows.Scan(&c.UserID, &c.Weight); err != nil {
+		if err := rows.Scan(&c.UserID, &c.ActivityScore, &c.ContributionCents, &c.RecentWins); err != nil {
 			return nil, err
 		}
-		if c.Weight > 0 {
-			candidates = append(candidates, c)
-		}
+		c.Weight = applyWeighting(weighting, c)
+		candidates = append(candidates, c)
 	}
 	return candidates, rows.Err()
 }
 
-func (s *Service) insertRound(ctx context.Context, tx pgx.Tx, triggerEventID, caseID, streamSessionID, winnerID *int64, triggerType string, prizeCents int64, details json.RawMessage) (Round, error) {
+func (s *Service) insertRound(ctx context.Context, tx pgx.Tx, draw fairDraw, triggerEventID, caseID, streamSessionID *int64, triggerType string, prizeCents int64, details json.RawMessage) (Round, error) {
 	var round Round
 	err := tx.QueryRow(ctx, `
-INSERT INTO lottery_rounds (trigger_event_id, case_id, stream_session_id, winner_user_id, trigger_type, prize_cents, details)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
-RETURNING id, trigger_event_id, case_id, stream_session_id, winner_user_id, trigger_type, prize_cents, details, created_at
-`, triggerEventID, caseID, streamSessionID, winnerID, triggerType, prizeCents, details).Scan(
+INSERT INTO lottery_rounds (id, seed_id, trigger_event_id, case_id, stream_session_id, winner_user_id, trigger_type, prize_cents, details)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, trigger_event_id, case_id, stream_session_id, winner_user_id, trigger_type, prize_cents, details, prize_item_id, created_at
+`, draw.RoundID, draw.SeedID, triggerEventID, caseID, streamSessionID, draw.WinnerID, triggerType, prizeCents, details).Scan(
 		&round.ID,
 		&round.TriggerEvent,
 		&round.CaseID,
@@ -294,33 +310,8 @@ RETURNING id, trigger_event_id, case_id, stream_session_id, winner_user_id, trig
 		&round.TriggerType,
 		&round.PrizeCents,
 		&round.Details,
+		&round.PrizeItemID,
 		&round.CreatedAt,
 	)
 	return round, err
 }
-
-func chooseWinner(candidates []weightedUser) (int64, error) {
-	totalWeight := int64(0)
-	for _, candidate := range candidates {
-		totalWeight += candidate.Weight
-	}
-	if totalWeight <= 0 {
-		return 0, errors.New("invalid total weight")
-	}
-
-	r, err := rand.Int(rand.Reader, big.NewInt(totalWeight))
-	if err != nil {
-		return 0, err
-	}
-	threshold := r.Int64()
-
-	running := int64(0)
-	for _, candidate := range candidates {
-		running += candidate.Weight
-		if threshold < running {
-			return candidate.UserID, nil
-		}
-	}
-
-	return candidates[len(candidates)-1].UserID, nil
-}
@@ -0,0 +1,116 @@
+package lottery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	WeightCombined     = "combined"
+	WeightUniform      = "uniform"
+	WeightActivity     = "activity"
+	WeightContribution = "contribution"
+	WeightCapped       = "capped"
+	WeightWinDecay     = "win_decay"
+)
+
+const defaultWeightCap = 10
+
+type Weighting struct {
+	Strategy string `json:"strategy"`
+	Cap      int64  `json:"cap,omitempty"`
+}
+
+type DrawOptions struct {
+	Weighting   Weighting
+	Limits      WinLimits
+	ClaimWindow time.Duration
+}
+
+type CandidateWeight struct {
+	UserID            int64  `json:"user_id"`
+	Weight            int64  `json:"weight"`
+	ActivityScore     int64  `json:"activity_score"`
+	ContributionCents int64  `json:"contribution_cents"`
+	RecentWins        int64  `json:"recent_wins"`
+	Limited           string `json:"limited,omitempty"`
+}
+
+func NormalizeWeighting(w Weighting) (Weighting, error) {
+	w.Strategy = strings.ToLower(strings.TrimSpace(w.Strategy))
+	if w.Strategy == "" {
+		w.Strategy = WeightCombined
+	}
+	if w.Cap < 0 {
+		return Weighting{}, errors.New("weighting cap cannot be negative")
+	}
+	switch w.Strategy {
+	case WeightCombined, WeightUniform, WeightActivity, WeightContribution, WeightWinDecay:
+		w.Cap = 0
+	case WeightCapped:
+		if w.Cap == 0 {
+			w.Cap = defaultWeightCap
+		}
+	default:
+		return Weighting{}, errors.New("weighting strategy must be combined, uniform, activity, contribution, capped or win_decay")
+	}
+	return w, nil
+}
+
+func (s *Service) CandidateWeights(ctx context.Context, userIDs []int64, streamSessionID *int64, weighting Weighting) (map[int64]int64, error) {
+	candidates, err := s.loadCandidatesByUsers(ctx, userIDs, streamSessionID, weighting)
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[int64]int64, len(candidates))
+	for _, c := range candidates {
+		weights[c.UserID] = c.Weight
+	}
+	return weights, nil
+}
+
+func applyWeighting(w Weighting, c weightedUser) int64 {
+	combined := c.ActivityScore + c.ContributionCents/100
+	var weight int64
+	switch w.Strategy {
+	case WeightUniform:
+		weight = 1
+	case WeightActivity:
+		weight = c.ActivityScore
+	case WeightContribution:
+		weight = c.ContributionCents / 100
+	case WeightCapped:
+		weight = combined
+		if w.Cap > 0 && weight > w.Cap {
+			weight = w.Cap
+		}
+	case WeightWinDecay:
+		weight = combined
+		for i := int64(0); i < c.RecentWins && weight > 1; i++ {
+			weight /= 2
+		}
+	default:
+		weight = combined
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	return weight
+}
+
+func candidateBreakdown(candidates []weightedUser) []CandidateWeight {
+	breakdown := make([]CandidateWeight, 0, len(candidates))
+	for _, c := range candidates {
+		breakdown = append(breakdown, CandidateWeight{
+			UserID:            c.UserID,
+			Weight:            c.Weight,
+			ActivityScore:     c.ActivityScore,
+			ContributionCents: c.ContributionCents,
+			RecentWins:        c.RecentWins,
+			Limited:           c.Limited,
+		})
+	}
+	return breakdown
+}
@@ -0,0 +1,176 @@
+package predictions
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/auth"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/httpx"
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+type stakeRequest struct {
+	OutcomeID   int64 `json:"outcome_id"`
+	AmountCents int64 `json:"amount_cents"`
+}
+
+type resolveRequest struct {
+	OutcomeID int64 `json:"outcome_id"`
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateParams
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	prediction, err := h.svc.Create(r.Context(), user.ID, req)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"prediction": prediction})
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var sessionID *int64
+	if raw := r.URL.Query().Get("session_id"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, "invalid session id")
+			return
+		}
+		sessionID = &parsed
+	}
+	limit := 25
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil {
+			limit = parsed
+		}
+	}
+
+	predictions, err := h.svc.List(r.Context(), user.ID, r.URL.Query().Get("status"), sessionID, limit)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list predictions")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"predictions": predictions})
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	predictionID, err := strconv.ParseInt(chi.URLParam(r, "predictionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid prediction id")
+		return
+	}
+
+	prediction, err := h.svc.Get(r.Context(), predictionID, user.ID)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"prediction": prediction})
+}
+
+func (h *Handler) Stake(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	predictionID, err := strconv.ParseInt(chi.URLParam(r, "predictionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid prediction id")
+		return
+	}
+
+	var req stakeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	prediction, err := h.svc.PlaceStake(r.Context(), predictionID, req.OutcomeID, user.ID, req.AmountCents)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"prediction": prediction})
+}
+
+func (h *Handler) Resolve(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	predictionID, err := strconv.ParseInt(chi.URLParam(r, "predictionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid prediction id")
+		return
+	}
+
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	prediction, err := h.svc.ResolveManually(r.Context(), user.ID, predictionID, req.OutcomeID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"prediction": prediction})
+}
+
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	predictionID, err := strconv.ParseInt(chi.URLParam(r, "predictionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid prediction id")
+		return
+	}
+
+	prediction, err := h.svc.Cancel(r.Context(), user.ID, predictionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"prediction": prediction})
+}
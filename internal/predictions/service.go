@@ -0,0 +1,596 @@
+package predictions
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/wallet"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Service struct {
+	db     *pgxpool.Pool
+	wallet *wallet.Service
+}
+
+type Prediction struct {
+	ID               int64      `json:"id"`
+	StreamerID       int64      `json:"streamer_id"`
+	StreamSessionID  *int64     `json:"stream_session_id,omitempty"`
+	Question         string     `json:"question"`
+	Status           string     `json:"status"`
+	LocksAt          time.Time  `json:"locks_at"`
+	ResolvesAt       time.Time  `json:"resolves_at"`
+	WinningOutcomeID *int64     `json:"winning_outcome_id,omitempty"`
+	ResolutionSource string     `json:"resolution_source,omitempty"`
+	ResolutionEvent  *int64     `json:"resolution_event_id,omitempty"`
+	PotCents         int64      `json:"pot_cents"`
+	Outcomes         []Outcome  `json:"outcomes"`
+	MyStake          *Stake     `json:"my_stake,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+}
+
+type Outcome struct {
+	ID         int64   `json:"id"`
+	Label      string  `json:"label"`
+	EventType  string  `json:"event_type,omitempty"`
+	StakeCents int64   `json:"stake_cents"`
+	Stakers    int64   `json:"stakers"`
+	Odds       float64 `json:"odds"`
+}
+
+type Stake struct {
+	OutcomeID   int64      `json:"outcome_id"`
+	AmountCents int64      `json:"amount_cents"`
+	PayoutCents int64      `json:"payout_cents"`
+	SettledAt   *time.Time `json:"settled_at,omitempty"`
+}
+
+type OutcomeParams struct {
+	Label     string `json:"label"`
+	EventType string `json:"event_type"`
+}
+
+type CreateParams struct {
+	StreamSessionID *int64          `json:"stream_session_id"`
+	Question        string          `json:"question"`
+	Outcomes        []OutcomeParams `json:"outcomes"`
+	LocksAt         time.Time       `json:"locks_at"`
+	ResolvesAt      time.Time       `json:"resolves_at"`
+}
+
+const predictionColumns = `id, streamer_id, stream_session_id, question, status, locks_at, resolves_at, winning_outcome_id, COALESCE(resolution_source, ''), resolution_event_id, created_at, resolved_at`
+
+func NewService(db *pgxpool.Pool, wallet *wallet.Service) *Service {
+	return &Service{db: db, wallet: wallet}
+}
+
+func (s *Service) Create(ctx context.Context, streamerID int64, p CreateParams) (Prediction, error) {
+	p.Question = strings.TrimSpace(p.Question)
+	if p.Question == "" {
+		return Prediction{}, errors.New("question is required")
+	}
+	if len(p.Outcomes) < 2 || len(p.Outcomes) > 10 {
+		return Prediction{}, errors.New("a prediction needs between 2 and 10 outcomes")
+	}
+	defaults := 0
+	events := make(map[string]bool)
+	for i := range p.Outcomes {
+		p.Outcomes[i].Label = strings.TrimSpace(p.Outcomes[i].Label)
+		p.Outcomes[i].EventType = strings.ToLower(strings.TrimSpace(p.Outcomes[i].EventType))
+		if p.Outcomes[i].Label == "" {
+			return Prediction{}, errors.New("every outcome needs a label")
+		}
+		if p.Outcomes[i].EventType == "" {
+			defaults++
+			continue
+		}
+		if events[p.Outcomes[i].EventType] {
+			return Prediction{}, errors.New("each event_type can resolve only one outcome")
+		}
+		events[p.Outcomes[i].EventType] = true
+	}
+	if defaults > 1 {
+		return Prediction{}, errors.New("only one outcome can have no event_type")
+	}
+	if p.LocksAt.IsZero() {
+		p.LocksAt = time.Now().Add(2 * time.Minute)
+	}
+	if !p.LocksAt.After(time.Now()) {
+		return Prediction{}, errors.New("locks_at must be in the future")
+	}
+	if !p.ResolvesAt.After(p.LocksAt) {
+		return Prediction{}, errors.New("resolves_at must be after locks_at")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Prediction{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if p.StreamSessionID != nil {
+		var valid bool
+		if err := tx.QueryRow(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM stream_sessions
+	WHERE id = $1 AND streamer_id = $2 AND status = 'active'
+)
+`, *p.StreamSessionID, streamerID).Scan(&valid); err != nil {
+			return Prediction{}, err
+		}
+		if !valid {
+			return Prediction{}, errors.New("stream_session_id is invalid or not active")
+		}
+	}
+
+	var id int64
+	if err := tx.QueryRow(ctx, `
+INSERT INTO predictions (streamer_id, stream_session_id, question, locks_at, resolves_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id
+`, streamerID, p.StreamSessionID, p.Question, p.LocksAt, p.ResolvesAt).Scan(&id); err != nil {
+		return Prediction{}, err
+	}
+	for i, o := range p.Outcomes {
+		if _, err := tx.Exec(ctx, `
+INSERT INTO prediction_outcomes (prediction_id, position, label, event_type)
+VALUES ($1, $2, $3, NULLIF($4, ''))
+`, id, i, o.Label, o.EventType); err != nil {
+			return Prediction{}, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Prediction{}, err
+	}
+	return s.Get(ctx, id, streamerID)
+}
+
+func (s *Service) Get(ctx context.Context, predictionID, userID int64) (Prediction, error) {
+	p, err := scanPrediction(s.db.QueryRow(ctx, `SELECT `+predictionColumns+` FROM predictions WHERE id = $1`, predictionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Prediction{}, errors.New("prediction not found")
+	}
+	if err != nil {
+		return Prediction{}, err
+	}
+	if err := s.loadOutcomes(ctx, &p, userID); err != nil {
+		return Prediction{}, err
+	}
+	return p, nil
+}
+
+func (s *Service) List(ctx context.Context, userID int64, status string, streamSessionID *int64, limit int) ([]Prediction, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 25
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT `+predictionColumns+`
+FROM predictions
+WHERE ($1 = '' OR status = $1)
+  AND ($2::bigint IS NULL OR stream_session_id = $2)
+ORDER BY created_at DESC
+LIMIT $3
+`, strings.ToLower(strings.TrimSpace(status)), streamSessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Prediction, 0)
+	for rows.Next() {
+		p, err := scanPrediction(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if err := s.loadOutcomes(ctx, &list[i], userID); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func (s *Service) PlaceStake(ctx context.Context, predictionID, outcomeID, userID, amountCents int64) (Prediction, error) {
+	if amountCents <= 0 {
+		return Prediction{}, errors.New("amount_cents must be positive")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Prediction{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	p, err := scanPrediction(tx.QueryRow(ctx, `SELECT `+predictionColumns+` FROM predictions WHERE id = $1 FOR UPDATE`, predictionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Prediction{}, errors.New("prediction not found")
+	}
+	if err != nil {
+		return Prediction{}, err
+	}
+	if p.Status != "open" || !time.Now().Before(p.LocksAt) {
+		return Prediction{}, errors.New("prediction is locked")
+	}
+	if p.StreamerID == userID {
+		return Prediction{}, errors.New("you cannot stake on your own prediction")
+	}
+	if p.StreamSessionID != nil {
+		var joined bool
+		if err := tx.QueryRow(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM stream_participants
+	WHERE stream_session_id = $1 AND user_id = $2
+)
+`, *p.StreamSessionID, userID).Scan(&joined); err != nil {
+			return Prediction{}, err
+		}
+		if !joined {
+			return Prediction{}, errors.New("join stream invite first to stake on this prediction")
+		}
+	}
+
+	var valid bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM prediction_outcomes WHERE id = $1 AND prediction_id = $2)`, outcomeID, predictionID).Scan(&valid); err != nil {
+		return Prediction{}, err
+	}
+	if !valid {
+		return Prediction{}, errors.New("outcome not found")
+	}
+
+	var existing int64
+	err = tx.QueryRow(ctx, `SELECT outcome_id FROM prediction_stakes WHERE prediction_id = $1 AND user_id = $2`, predictionID, userID).Scan(&existing)
+	if err == nil && existing != outcomeID {
+		return Prediction{}, errors.New("you already backed another outcome")
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return Prediction{}, err
+	}
+
+	if _, err := s.wallet.AdjustBalance(ctx, tx, userID, -amountCents, "prediction_stake", map[string]interface{}{"prediction_id": predictionID, "outcome_id": outcomeID}); err != nil {
+		return Prediction{}, err
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO prediction_stakes (prediction_id, outcome_id, user_id, amount_cents)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (prediction_id, user_id)
+DO UPDATE SET amount_cents = prediction_stakes.amount_cents + EXCLUDED.amount_cents
+`, predictionID, outcomeID, userID, amountCents); err != nil {
+		return Prediction{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Prediction{}, err
+	}
+	return s.Get(ctx, predictionID, userID)
+}
+
+func (s *Service) ResolveManually(ctx context.Context, streamerID, predictionID, outcomeID int64) (Prediction, error) {
+	if err := s.resolve(ctx, predictionID, &streamerID, &outcomeID, "manual", nil); err != nil {
+		return Prediction{}, err
+	}
+	return s.Get(ctx, predictionID, streamerID)
+}
+
+func (s *Service) Cancel(ctx context.Context, streamerID, predictionID int64) (Prediction, error) {
+	if err := s.resolve(ctx, predictionID, &streamerID, nil, "cancelled", nil); err != nil {
+		return Prediction{}, err
+	}
+	return s.Get(ctx, predictionID, streamerID)
+}
+
+func (s *Service) HandleGameEvent(ctx context.Context, streamerID int64, eventType string, eventID *int64) {
+	rows, err := s.db.Query(ctx, `
+SELECT p.id, o.id
+FROM predictions p
+JOIN prediction_outcomes o ON o.prediction_id = p.id
+WHERE p.streamer_id = $1 AND p.status = 'open' AND p.resolves_at > NOW() AND o.event_type = $2
+`, streamerID, strings.ToLower(strings.TrimSpace(eventType)))
+	if err != nil {
+		log.Printf("prediction event lookup failed: %v", err)
+		return
+	}
+	type match struct{ predictionID, outcomeID int64 }
+	matches := make([]match, 0)
+	for rows.Next() {
+		var m match
+		if err := rows.Scan(&m.predictionID, &m.outcomeID); err != nil {
+			rows.Close()
+			log.Printf("prediction event lookup failed: %v", err)
+			return
+		}
+		matches = append(matches, m)
+	}
+	rows.Close()
+
+	for _, m := range matches {
+		outcomeID := m.outcomeID
+		if err := s.resolve(ctx, m.predictionID, nil, &outcomeID, "event", eventID); err != nil {
+			log.Printf("prediction resolve failed: prediction=%d err=%v", m.predictionID, err)
+		}
+	}
+}
+
+func (s *Service) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.resolveExpired(ctx); err != nil {
+			log.Printf("prediction scheduler failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) resolveExpired(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+SELECT p.id, o.id
+FROM predictions p
+LEFT JOIN prediction_outcomes o ON o.prediction_id = p.id AND o.event_type IS NULL
+WHERE p.status = 'open' AND p.resolves_at <= NOW()
+ORDER BY p.resolves_at
+LIMIT 50
+`)
+	if err != nil {
+		return err
+	}
+	type expired struct {
+		predictionID int64
+		outcomeID    *int64
+	}
+	due := make([]expired, 0)
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.predictionID, &e.outcomeID); err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range due {
+		source := "timeout"
+		if e.outcomeID == nil {
+			source = "expired"
+		}
+		if err := s.resolve(ctx, e.predictionID, nil, e.outcomeID, source, nil); err != nil {
+			log.Printf("prediction expiry failed: prediction=%d err=%v", e.predictionID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) resolve(ctx context.Context, predictionID int64, streamerID, outcomeID *int64, source string, eventID *int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	p, err := scanPrediction(tx.QueryRow(ctx, `SELECT `+predictionColumns+` FROM predictions WHERE id = $1 FOR UPDATE`, predictionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("prediction not found")
+	}
+	if err != nil {
+		return err
+	}
+	if streamerID != nil && p.StreamerID != *streamerID {
+		return errors.New("not your prediction")
+	}
+	if p.Status != "open" {
+		return errors.New("prediction is already settled")
+	}
+	if source == "manual" && time.Now().Before(p.LocksAt) {
+		return errors.New("prediction can only be resolved after locks_at")
+	}
+	if outcomeID != nil {
+		var valid bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM prediction_outcomes WHERE id = $1 AND prediction_id = $2)`, *outcomeID, predictionID).Scan(&valid); err != nil {
+			return err
+		}
+		if !valid {
+			return errors.New("outcome not found")
+		}
+	}
+
+	rows, err := tx.Query(ctx, `SELECT id, outcome_id, user_id, amount_cents FROM prediction_stakes WHERE prediction_id = $1 ORDER BY amount_cents DESC, id`, predictionID)
+	if err != nil {
+		return err
+	}
+	stakes := make([]stakeRow, 0)
+	for rows.Next() {
+		var st stakeRow
+		if err := rows.Scan(&st.ID, &st.OutcomeID, &st.UserID, &st.AmountCents); err != nil {
+			rows.Close()
+			return err
+		}
+		stakes = append(stakes, st)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	winning := int64(0)
+	if outcomeID != nil {
+		winning = *outcomeID
+	}
+	payouts := distribute(stakes, winning)
+	status := "resolved"
+	if outcomeID == nil || allRefunds(stakes, winning) {
+		status = "refunded"
+	}
+	if source == "cancelled" {
+		status = "cancelled"
+	}
+
+	for _, st := range stakes {
+		payout := payouts[st.ID]
+		if payout > 0 {
+			reason := "prediction_payout"
+			if status != "resolved" {
+				reason = "prediction_refund"
+			}
+			if _, err := s.wallet.AdjustBalance(ctx, tx, st.UserID, payout, reason, map[string]interface{}{"prediction_id": predictionID, "stake_id": st.ID}); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(ctx, `UPDATE prediction_stakes SET payout_cents = $2, settled_at = NOW() WHERE id = $1`, st.ID, payout); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+UPDATE predictions
+SET status = $2, winning_outcome_id = $3, resolution_source = $4, resolution_event_id = $5, resolved_at = NOW()
+WHERE id = $1
+`, predictionID, status, outcomeID, source, eventID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+type stakeRow struct {
+	ID          int64
+	OutcomeID   int64
+	UserID      int64
+	AmountCents int64
+}
+
+func distribute(stakes []stakeRow, winningOutcomeID int64) map[int64]int64 {
+	payouts := make(map[int64]int64, len(stakes))
+	var pot, winningPool int64
+	for _, st := range stakes {
+		pot += st.AmountCents
+		if st.OutcomeID == winningOutcomeID {
+			winningPool += st.AmountCents
+		}
+	}
+	if winningPool == 0 {
+		for _, st := range stakes {
+			payouts[st.ID] = st.AmountCents
+		}
+		return payouts
+	}
+
+	var paid int64
+	bigPot, bigPool := big.NewInt(pot), big.NewInt(winningPool)
+	for _, st := range stakes {
+		if st.OutcomeID != winningOutcomeID {
+			continue
+		}
+		share := new(big.Int).Mul(bigPot, big.NewInt(st.AmountCents))
+		share.Quo(share, bigPool)
+		payouts[st.ID] = share.Int64()
+		paid += share.Int64()
+	}
+	for _, st := range stakes {
+		if paid >= pot {
+			break
+		}
+		if st.OutcomeID == winningOutcomeID {
+			payouts[st.ID]++
+			paid++
+		}
+	}
+	return payouts
+}
+
+func allRefunds(stakes []stakeRow, winningOutcomeID int64) bool {
+	for _, st := range stakes {
+		if st.OutcomeID == winningOutcomeID {
+			return false
+		}
+	}
+	return len(stakes) > 0
+}
+
+func (s *Service) loadOutcomes(ctx context.Context, p *Prediction, userID int64) error {
+	rows, err := s.db.Query(ctx, `
+SELECT o.id, o.label, COALESCE(o.event_type, ''), COALESCE(SUM(st.amount_cents), 0), COUNT(st.id)
+FROM prediction_outcomes o
+LEFT JOIN prediction_stakes st ON st.outcome_id = o.id
+WHERE o.prediction_id = $1
+GROUP BY o.id
+ORDER BY o.position
+`, p.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	p.Outcomes = make([]Outcome, 0)
+	p.PotCents = 0
+	for rows.Next() {
+		var o Outcome
+		if err := rows.Scan(&o.ID, &o.Label, &o.EventType, &o.StakeCents, &o.Stakers); err != nil {
+			return err
+		}
+		p.PotCents += o.StakeCents
+		p.Outcomes = append(p.Outcomes, o)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range p.Outcomes {
+		if p.Outcomes[i].StakeCents > 0 {
+			p.Outcomes[i].Odds = float64(p.PotCents) / float64(p.Outcomes[i].StakeCents)
+		}
+	}
+
+	var st Stake
+	err = s.db.QueryRow(ctx, `
+SELECT outcome_id, amount_cents, payout_cents, settled_at
+FROM prediction_stakes
+WHERE prediction_id = $1 AND user_id = $2
+`, p.ID, userID).Scan(&st.OutcomeID, &st.AmountCents, &st.PayoutCents, &st.SettledAt)
+	if err == nil {
+		p.MyStake = &st
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func scanPrediction(row pgx.Row) (Prediction, error) {
+	var p Prediction
+	err := row.Scan(
+		&p.ID,
+		&p.StreamerID,
+		&p.StreamSessionID,
+		&p.Question,
+		&p.Status,
+		&p.LocksAt,
+		&p.ResolvesAt,
+		&p.WinningOutcomeID,
+		&p.ResolutionSource,
+		&p.ResolutionEvent,
+		&p.CreatedAt,
+		&p.ResolvedAt,
+	)
+	return p, err
+}
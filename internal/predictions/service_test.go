@@ -0,0 +1,80 @@
+package predictions
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistribute(t *testing.T) {
+	tests := []struct {
+		name    string
+		stakes  []stakeRow
+		winning int64
+		want    map[int64]int64
+	}{
+		{
+			name: "proportional split",
+			stakes: []stakeRow{
+				{ID: 1, OutcomeID: 10, AmountCents: 300},
+				{ID: 2, OutcomeID: 10, AmountCents: 100},
+				{ID: 3, OutcomeID: 20, AmountCents: 400},
+			},
+			winning: 10,
+			want:    map[int64]int64{1: 600, 2: 200},
+		},
+		{
+			name: "remainder goes to the largest winning stakes first",
+			stakes: []stakeRow{
+				{ID: 1, OutcomeID: 10, AmountCents: 100},
+				{ID: 2, OutcomeID: 10, AmountCents: 100},
+				{ID: 3, OutcomeID: 10, AmountCents: 100},
+				{ID: 4, OutcomeID: 20, AmountCents: 101},
+			},
+			winning: 10,
+			want:    map[int64]int64{1: 134, 2: 134, 3: 133},
+		},
+		{
+			name: "no winning stakes refunds everyone",
+			stakes: []stakeRow{
+				{ID: 1, OutcomeID: 10, AmountCents: 250},
+				{ID: 2, OutcomeID: 20, AmountCents: 75},
+			},
+			winning: 30,
+			want:    map[int64]int64{1: 250, 2: 75},
+		},
+		{
+			name: "cancelled prediction refunds everyone",
+			stakes: []stakeRow{
+				{ID: 1, OutcomeID: 10, AmountCents: 5},
+			},
+			winning: 0,
+			want:    map[int64]int64{1: 5},
+		},
+		{
+			name: "large stakes do not overflow",
+			stakes: []stakeRow{
+				{ID: 1, OutcomeID: 10, AmountCents: math.MaxInt64 / 4},
+				{ID: 2, OutcomeID: 10, AmountCents: math.MaxInt64 / 4},
+				{ID: 3, OutcomeID: 20, AmountCents: math.MaxInt64 / 4},
+			},
+			winning: 10,
+			want:    map[int64]int64{1: math.MaxInt64/4 + math.MaxInt64/8 + 1, 2: math.MaxInt64/4 + math.MaxInt64/8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := distribute(tt.stakes, tt.winning)
+			var pot, paid int64
+			for _, st := range tt.stakes {
+				pot += st.AmountCents
+				paid += got[st.ID]
+				if got[st.ID] != tt.want[st.ID] {
+					t.Errorf("stake %d payout = %d, want %d", st.ID, got[st.ID], tt.want[st.ID])
+				}
+			}
+			if paid != pot {
+				t.Errorf("paid %d, want the whole pot %d", paid, pot)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package raffles
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/auth"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/httpx"
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+type ticketRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req CreateParams
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	raffle, err := h.svc.Create(r.Context(), user.ID, req)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"raffle": raffle})
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var sessionID *int64
+	if raw := r.URL.Query().Get("session_id"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, "invalid session id")
+			return
+		}
+		sessionID = &parsed
+	}
+	limit := 25
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil {
+			limit = parsed
+		}
+	}
+
+	raffles, err := h.svc.List(r.Context(), user.ID, r.URL.Query().Get("status"), sessionID, limit)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list raffles")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"raffles": raffles})
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	raffleID, err := strconv.ParseInt(chi.URLParam(r, "raffleID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid raffle id")
+		return
+	}
+
+	raffle, err := h.svc.Get(r.Context(), raffleID, user.ID)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"raffle": raffle})
+}
+
+func (h *Handler) BuyTickets(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	raffleID, err := strconv.ParseInt(chi.URLParam(r, "raffleID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid raffle id")
+		return
+	}
+
+	var req ticketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+
+	raffle, err := h.svc.BuyTickets(r.Context(), raffleID, user.ID, req.Quantity)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"raffle": raffle})
+}
+
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	raffleID, err := strconv.ParseInt(chi.URLParam(r, "raffleID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid raffle id")
+		return
+	}
+
+	raffle, err := h.svc.Cancel(r.Context(), user.ID, raffleID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"raffle": raffle})
+}
@@ -0,0 +1,513 @@
+package raffles
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/inventory"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/wallet"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Service struct {
+	db        *pgxpool.Pool
+	wallet    *wallet.Service
+	lottery   *lottery.Service
+	inventory *inventory.Service
+}
+
+type Raffle struct {
+	ID                int64      `json:"id"`
+	StreamerID        int64      `json:"streamer_id"`
+	StreamSessionID   *int64     `json:"stream_session_id,omitempty"`
+	Title             string     `json:"title"`
+	PrizeType         string     `json:"prize_type"`
+	PrizeName         string     `json:"prize_name"`
+	PrizeCents        int64      `json:"prize_cents"`
+	TicketPriceCents  int64      `json:"ticket_price_cents"`
+	MaxTicketsPerUser int        `json:"max_tickets_per_user"`
+	EndsAt            time.Time  `json:"ends_at"`
+	Status            string     `json:"status"`
+	LotteryRoundID    *int64     `json:"lottery_round_id,omitempty"`
+	WinnerUserID      *int64     `json:"winner_user_id,omitempty"`
+	TicketsSold       int64      `json:"tickets_sold"`
+	Entrants          int64      `json:"entrants"`
+	MyTickets         int64      `json:"my_tickets"`
+	CreatedAt         time.Time  `json:"created_at"`
+	CancelledAt       *time.Time `json:"cancelled_at,omitempty"`
+}
+
+type CreateParams struct {
+	StreamSessionID   *int64    `json:"stream_session_id"`
+	Title             string    `json:"title"`
+	PrizeType         string    `json:"prize_type"`
+	PrizeName         string    `json:"prize_name"`
+	PrizeCents        int64     `json:"prize_cents"`
+	TicketPriceCents  int64     `json:"ticket_price_cents"`
+	MaxTicketsPerUser int       `json:"max_tickets_per_user"`
+	EndsAt            time.Time `json:"ends_at"`
+}
+
+const raffleColumns = `r.id, r.streamer_id, r.stream_session_id, r.title, r.prize_type, r.prize_name, r.prize_cents, r.ticket_price_cents, r.max_tickets_per_user, r.ends_at, r.status, r.lottery_round_id, r.winner_user_id, r.created_at, r.cancelled_at,
+       COALESCE((SELECT SUM(t.quantity) FROM raffle_tickets t WHERE t.raffle_id = r.id AND t.refunded_at IS NULL), 0),
+       COALESCE((SELECT COUNT(DISTINCT t.user_id) FROM raffle_tickets t WHERE t.raffle_id = r.id AND t.refunded_at IS NULL), 0),
+       COALESCE((SELECT SUM(t.quantity) FROM raffle_tickets t WHERE t.raffle_id = r.id AND t.refunded_at IS NULL AND t.user_id = $1), 0)`
+
+func NewService(db *pgxpool.Pool, wallet *wallet.Service, lottery *lottery.Service, inventory *inventory.Service) *Service {
+	return &Service{db: db, wallet: wallet, lottery: lottery, inventory: inventory}
+}
+
+func (s *Service) Create(ctx context.Context, streamerID int64, p CreateParams) (Raffle, error) {
+	p.Title = strings.TrimSpace(p.Title)
+	p.PrizeName = strings.TrimSpace(p.PrizeName)
+	p.PrizeType = strings.ToLower(strings.TrimSpace(p.PrizeType))
+	if p.Title == "" || p.PrizeName == "" {
+		return Raffle{}, errors.New("title and prize_name are required")
+	}
+	if p.PrizeType != "skin" && p.PrizeType != "case" {
+		return Raffle{}, errors.New("prize_type must be skin or case")
+	}
+	if p.PrizeCents < 0 {
+		return Raffle{}, errors.New("prize_cents cannot be negative")
+	}
+	if p.TicketPriceCents <= 0 {
+		return Raffle{}, errors.New("ticket_price_cents must be positive")
+	}
+	if p.MaxTicketsPerUser < 0 {
+		return Raffle{}, errors.New("max_tickets_per_user cannot be negative")
+	}
+	if !p.EndsAt.After(time.Now().Add(time.Minute)) {
+		return Raffle{}, errors.New("ends_at must be at least a minute in the future")
+	}
+
+	if p.StreamSessionID != nil {
+		var valid bool
+		err := s.db.QueryRow(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM stream_sessions
+	WHERE id = $1 AND streamer_id = $2 AND status = 'active'
+)
+`, *p.StreamSessionID, streamerID).Scan(&valid)
+		if err != nil {
+			return Raffle{}, err
+		}
+		if !valid {
+			return Raffle{}, errors.New("stream_session_id is invalid or not active")
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Raffle{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `
+INSERT INTO raffles (streamer_id, stream_session_id, title, prize_type, prize_name, prize_cents, prize_escrow_cents, ticket_price_cents, max_tickets_per_user, ends_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6, $7, $8, $9)
+RETURNING id
+`, streamerID, p.StreamSessionID, p.Title, p.PrizeType, p.PrizeName, p.PrizeCents, p.TicketPriceCents, p.MaxTicketsPerUser, p.EndsAt).Scan(&id)
+	if err != nil {
+		return Raffle{}, err
+	}
+	if p.PrizeCents > 0 {
+		if _, err := s.wallet.AdjustBalance(ctx, tx, streamerID, -p.PrizeCents, "raffle_escrow", map[string]interface{}{"raffle_id": id}); err != nil {
+			return Raffle{}, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Raffle{}, err
+	}
+	return s.Get(ctx, id, streamerID)
+}
+
+func (s *Service) Get(ctx context.Context, raffleID, userID int64) (Raffle, error) {
+	raffle, err := scanRaffle(s.db.QueryRow(ctx, `
+SELECT `+raffleColumns+`
+FROM raffles r
+WHERE r.id = $2
+`, userID, raffleID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Raffle{}, errors.New("raffle not found")
+	}
+	return raffle, err
+}
+
+func (s *Service) List(ctx context.Context, userID int64, status string, streamSessionID *int64, limit int) ([]Raffle, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 25
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT `+raffleColumns+`
+FROM raffles r
+WHERE ($2 = '' OR r.status = $2)
+  AND ($3::bigint IS NULL OR r.stream_session_id = $3)
+ORDER BY r.created_at DESC
+LIMIT $4
+`, userID, strings.ToLower(strings.TrimSpace(status)), streamSessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	raffles := make([]Raffle, 0)
+	for rows.Next() {
+		raffle, err := scanRaffle(rows)
+		if err != nil {
+			return nil, err
+		}
+		raffles = append(raffles, raffle)
+	}
+	return raffles, rows.Err()
+}
+
+func (s *Service) BuyTickets(ctx context.Context, raffleID, userID int64, quantity int) (Raffle, error) {
+	if quantity <= 0 {
+		return Raffle{}, errors.New("quantity must be positive")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Raffle{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var streamerID int64
+	var streamSessionID *int64
+	var status string
+	var price int64
+	var maxPerUser int
+	var endsAt time.Time
+	err = tx.QueryRow(ctx, `
+SELECT streamer_id, stream_session_id, status, ticket_price_cents, max_tickets_per_user, ends_at
+FROM raffles
+WHERE id = $1
+FOR UPDATE
+`, raffleID).Scan(&streamerID, &streamSessionID, &status, &price, &maxPerUser, &endsAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Raffle{}, errors.New("raffle not found")
+	}
+	if err != nil {
+		return Raffle{}, err
+	}
+	if status != "open" || !time.Now().Before(endsAt) {
+		return Raffle{}, errors.New("raffle is closed")
+	}
+	if userID == streamerID {
+		return Raffle{}, errors.New("you cannot enter your own raffle")
+	}
+	if streamSessionID != nil {
+		var joined bool
+		if err := tx.QueryRow(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM stream_participants
+	WHERE stream_session_id = $1 AND user_id = $2
+)
+`, *streamSessionID, userID).Scan(&joined); err != nil {
+			return Raffle{}, err
+		}
+		if !joined {
+			return Raffle{}, errors.New("join stream invite first to enter this raffle")
+		}
+	}
+
+	if maxPerUser > 0 {
+		var owned int
+		if err := tx.QueryRow(ctx, `
+SELECT COALESCE(SUM(quantity), 0)
+FROM raffle_tickets
+WHERE raffle_id = $1 AND user_id = $2 AND refunded_at IS NULL
+`, raffleID, userID).Scan(&owned); err != nil {
+			return Raffle{}, err
+		}
+		if owned+quantity > maxPerUser {
+			return Raffle{}, errors.New("ticket limit reached for this raffle")
+		}
+	}
+
+	amount := price * int64(quantity)
+	if _, err := s.wallet.AdjustBalance(ctx, tx, userID, -amount, "raffle_ticket", map[string]interface{}{"raffle_id": raffleID, "quantity": quantity}); err != nil {
+		return Raffle{}, err
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO raffle_tickets (raffle_id, user_id, quantity, amount_cents)
+VALUES ($1, $2, $3, $4)
+`, raffleID, userID, quantity, amount); err != nil {
+		return Raffle{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Raffle{}, err
+	}
+	return s.Get(ctx, raffleID, userID)
+}
+
+func (s *Service) Cancel(ctx context.Context, streamerID, raffleID int64) (Raffle, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Raffle{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var ownerID int64
+	var status string
+	var endsAt time.Time
+	err = tx.QueryRow(ctx, `SELECT streamer_id, status, ends_at FROM raffles WHERE id = $1 FOR UPDATE`, raffleID).Scan(&ownerID, &status, &endsAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Raffle{}, errors.New("raffle not found")
+	}
+	if err != nil {
+		return Raffle{}, err
+	}
+	if ownerID != streamerID {
+		return Raffle{}, errors.New("not your raffle")
+	}
+	if status != "open" {
+		return Raffle{}, errors.New("only open raffles can be cancelled")
+	}
+	if !time.Now().Before(endsAt) {
+		return Raffle{}, errors.New("raffle has ended and is waiting to be drawn")
+	}
+
+	rows, err := tx.Query(ctx, `
+UPDATE raffle_tickets
+SET refunded_at = NOW()
+WHERE raffle_id = $1 AND refunded_at IS NULL
+RETURNING id, user_id, amount_cents
+`, raffleID)
+	if err != nil {
+		return Raffle{}, err
+	}
+	type refund struct {
+		ticketID, userID, amount int64
+	}
+	refunds := make([]refund, 0)
+	for rows.Next() {
+		var r refund
+		if err := rows.Scan(&r.ticketID, &r.userID, &r.amount); err != nil {
+			rows.Close()
+			return Raffle{}, err
+		}
+		refunds = append(refunds, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Raffle{}, err
+	}
+
+	for _, r := range refunds {
+		if _, err := s.wallet.AdjustBalance(ctx, tx, r.userID, r.amount, "raffle_refund", map[string]interface{}{"raffle_id": raffleID, "ticket_id": r.ticketID}); err != nil {
+			return Raffle{}, err
+		}
+	}
+
+	if err := s.refundEscrow(ctx, tx, raffleID, ownerID); err != nil {
+		return Raffle{}, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE raffles SET status = 'cancelled', cancelled_at = NOW(), updated_at = NOW() WHERE id = $1`, raffleID); err != nil {
+		return Raffle{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Raffle{}, err
+	}
+	return s.Get(ctx, raffleID, streamerID)
+}
+
+func (s *Service) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.drawDueRaffles(ctx); err != nil {
+			log.Printf("raffle scheduler failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) drawDueRaffles(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+SELECT id, streamer_id, stream_session_id, title, prize_type, prize_name, prize_cents
+FROM raffles
+WHERE status = 'open' AND ends_at <= NOW()
+ORDER BY ends_at
+LIMIT 50
+`)
+	if err != nil {
+		return err
+	}
+	due := make([]Raffle, 0)
+	for rows.Next() {
+		var r Raffle
+		if err := rows.Scan(&r.ID, &r.StreamerID, &r.StreamSessionID, &r.Title, &r.PrizeType, &r.PrizeName, &r.PrizeCents); err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range due {
+		if _, err := s.draw(ctx, r); err != nil {
+			log.Printf("raffle draw failed: raffle=%d err=%v", r.ID, err)
+		}
+	}
+	return s.refundUnclaimedEscrows(ctx)
+}
+
+func (s *Service) refundUnclaimedEscrows(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+SELECT id, streamer_id
+FROM raffles
+WHERE status = 'no_entries' AND prize_escrow_cents > 0
+LIMIT 50
+`)
+	if err != nil {
+		return err
+	}
+	type pending struct{ raffleID, streamerID int64 }
+	refunds := make([]pending, 0)
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.raffleID, &p.streamerID); err != nil {
+			rows.Close()
+			return err
+		}
+		refunds = append(refunds, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range refunds {
+		if err := s.refundEscrowNow(ctx, p.raffleID, p.streamerID); err != nil {
+			log.Printf("raffle escrow refund failed: raffle=%d err=%v", p.raffleID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) refundEscrowNow(ctx context.Context, raffleID, streamerID int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.refundEscrow(ctx, tx, raffleID, streamerID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Service) refundEscrow(ctx context.Context, tx pgx.Tx, raffleID, streamerID int64) error {
+	var escrow int64
+	err := tx.QueryRow(ctx, `
+UPDATE raffles r
+SET prize_escrow_cents = 0, updated_at = NOW()
+FROM (SELECT id, prize_escrow_cents FROM raffles WHERE id = $1 FOR UPDATE) old
+WHERE r.id = old.id AND old.prize_escrow_cents > 0
+RETURNING old.prize_escrow_cents
+`, raffleID).Scan(&escrow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.wallet.AdjustBalance(ctx, tx, streamerID, escrow, "raffle_escrow_refund", map[string]interface{}{"raffle_id": raffleID})
+	return err
+}
+
+func (s *Service) draw(ctx context.Context, r Raffle) (*lottery.Round, error) {
+	return s.lottery.DrawForRaffle(ctx, r.ID, r.StreamSessionID, r.PrizeCents, map[string]interface{}{
+		"prize_type": r.PrizeType,
+		"prize_name": r.PrizeName,
+		"title":      r.Title,
+	}, s.settle(r))
+}
+
+func (s *Service) settle(r Raffle) lottery.PrizeDeliverer {
+	return func(ctx context.Context, tx pgx.Tx, round lottery.Round) (int64, error) {
+		if round.WinnerUserID == nil {
+			return 0, errors.New("round has no winner")
+		}
+		if s.inventory == nil {
+			return 0, errors.New("inventory is not configured")
+		}
+
+		var proceeds, escrow int64
+		if err := tx.QueryRow(ctx, `
+SELECT COALESCE((SELECT SUM(amount_cents) FROM raffle_tickets WHERE raffle_id = $1 AND refunded_at IS NULL), 0),
+       prize_escrow_cents
+FROM raffles
+WHERE id = $1
+`, r.ID).Scan(&proceeds, &escrow); err != nil {
+			return 0, err
+		}
+		prizeCents := escrow
+		if unfunded := r.PrizeCents - escrow; unfunded > 0 {
+			covered := min(unfunded, proceeds)
+			prizeCents += covered
+			proceeds -= covered
+		}
+		if _, err := tx.Exec(ctx, `UPDATE raffles SET prize_escrow_cents = 0 WHERE id = $1`, r.ID); err != nil {
+			return 0, err
+		}
+		if proceeds > 0 {
+			if _, err := s.wallet.AdjustBalance(ctx, tx, r.StreamerID, proceeds, "raffle_proceeds", map[string]interface{}{"raffle_id": r.ID, "lottery_round_id": round.ID}); err != nil {
+				return 0, err
+			}
+		}
+
+		item, err := s.inventory.GrantItemTx(ctx, tx, *round.WinnerUserID, r.PrizeType, r.PrizeName, "restricted", "raffle", nil, map[string]interface{}{
+			"raffle_id":        r.ID,
+			"lottery_round_id": round.ID,
+			"price_cents":      prizeCents,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return item.ID, nil
+	}
+}
+
+func scanRaffle(row pgx.Row) (Raffle, error) {
+	var r Raffle
+	err := row.Scan(
+		&r.ID,
+		&r.StreamerID,
+		&r.StreamSessionID,
+		&r.Title,
+		&r.PrizeType,
+		&r.PrizeName,
+		&r.PrizeCents,
+		&r.TicketPriceCents,
+		&r.MaxTicketsPerUser,
+		&r.EndsAt,
+		&r.Status,
+		&r.LotteryRoundID,
+		&r.WinnerUserID,
+		&r.CreatedAt,
+		&r.CancelledAt,
+		&r.TicketsSold,
+		&r.Entrants,
+		&r.MyTickets,
+	)
+	return r, err
+}
@@ -0,0 +1,117 @@
+package steam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const cs2AppID = 730
+
+type Client interface {
+	GetSteamLevel(ctx context.Context, steamID string) (int, error)
+	GetCS2PlaytimeMinutes(ctx context.Context, steamID string) (int64, error)
+}
+
+func NewClient(apiKey string, stub bool, stubLevel int, stubPlaytimeMinutes int64) Client {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		if !stub {
+			return nil
+		}
+		return StubClient{Level: stubLevel, PlaytimeMinutes: stubPlaytimeMinutes}
+	}
+	return &WebAPIClient{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type StubClient struct {
+	Level           int
+	PlaytimeMinutes int64
+}
+
+func (c StubClient) GetSteamLevel(ctx context.Context, steamID string) (int, error) {
+	return c.Level, nil
+}
+
+func (c StubClient) GetCS2PlaytimeMinutes(ctx context.Context, steamID string) (int64, error) {
+	return c.PlaytimeMinutes, nil
+}
+
+type WebAPIClient struct {
+	apiKey string
+	client *http.Client
+}
+
+func (c *WebAPIClient) GetSteamLevel(ctx context.Context, steamID string) (int, error) {
+	values := url.Values{}
+	values.Set("steamid", steamID)
+
+	var payload struct {
+		Response struct {
+			PlayerLevel *int `json:"player_level"`
+		} `json:"response"`
+	}
+	if err := c.get(ctx, "IPlayerService/GetSteamLevel/v1/", values, &payload); err != nil {
+		return 0, err
+	}
+	if payload.Response.PlayerLevel == nil {
+		return 0, errors.New("steam level is not visible")
+	}
+	return *payload.Response.PlayerLevel, nil
+}
+
+func (c *WebAPIClient) GetCS2PlaytimeMinutes(ctx context.Context, steamID string) (int64, error) {
+	values := url.Values{}
+	values.Set("steamid", steamID)
+	values.Set("include_played_free_games", "1")
+	values.Set("appids_filter[0]", fmt.Sprint(cs2AppID))
+
+	var payload struct {
+		Response struct {
+			GameCount *int `json:"game_count"`
+			Games     []struct {
+				AppID           int   `json:"appid"`
+				PlaytimeForever int64 `json:"playtime_forever"`
+			} `json:"games"`
+		} `json:"response"`
+	}
+	if err := c.get(ctx, "IPlayerService/GetOwnedGames/v1/", values, &payload); err != nil {
+		return 0, err
+	}
+	if payload.Response.GameCount == nil {
+		return 0, errors.New("steam game details are private")
+	}
+	for _, game := range payload.Response.Games {
+		if game.AppID == cs2AppID {
+			return game.PlaytimeForever, nil
+		}
+	}
+	return 0, nil
+}
+
+func (c *WebAPIClient) get(ctx context.Context, method string, values url.Values, out interface{}) error {
+	values.Set("key", c.apiKey)
+	values.Set("format", "json")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.steampowered.com/"+method+"?"+values.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("steam web api http=%d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
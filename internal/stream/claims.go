@@ -0,0 +1,170 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Service) SetSessionClaimWindow(ctx context.Context, actorID, sessionID int64, minutes int) (int, error) {
+	if minutes < 0 || minutes > 24*60 {
+		return 0, errors.New("claim_window_minutes must be between 0 and 1440")
+	}
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageDraws)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := s.db.Exec(ctx, `UPDATE stream_sessions SET claim_window_minutes = $2 WHERE id = $1`, sessionID, minutes); err != nil {
+		return 0, err
+	}
+	s.auditSession(ctx, session, actorID, role, "set_claim_window", map[string]interface{}{"claim_window_minutes": minutes})
+	return minutes, nil
+}
+
+func (s *Service) ListMyClaims(ctx context.Context, userID int64) ([]lottery.Round, error) {
+	return s.lottery.ListPendingClaims(ctx, userID)
+}
+
+func (s *Service) ClaimPrize(ctx context.Context, userID, roundID int64) (lottery.Round, error) {
+	round, err := s.lottery.GetRound(ctx, roundID)
+	if err != nil {
+		return lottery.Round{}, errors.New("prize not found")
+	}
+
+	var details struct {
+		PrizeType string `json:"prize_type"`
+		PrizeName string `json:"prize_name"`
+		RuleID    *int64 `json:"rule_id"`
+		Note      string `json:"note"`
+	}
+	_ = json.Unmarshal(round.Details, &details)
+
+	var deliver lottery.PrizeDeliverer
+	if details.PrizeName != "" && round.StreamSessionID != nil {
+		metadata := map[string]interface{}{
+			"stream_session_id": *round.StreamSessionID,
+			"trigger_type":      round.TriggerType,
+		}
+		if details.RuleID != nil {
+			metadata["rule_id"] = *details.RuleID
+		}
+		if details.PrizeType == "" {
+			details.PrizeType = "case"
+		}
+		deliver = s.prizeDeliverer(details.PrizeType, details.PrizeName, round.PrizeCents, metadata)
+	}
+	claimed, err := s.lottery.ClaimPrize(ctx, roundID, userID, deliver)
+	if err != nil {
+		return lottery.Round{}, err
+	}
+	if claimed.TriggerType == "manual" && claimed.StreamSessionID != nil {
+		if session, err := s.getSession(ctx, *claimed.StreamSessionID); err == nil {
+			s.announceManualDraw(ctx, session, claimed, details.PrizeName, details.Note)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *Service) ClaimByTelegram(ctx context.Context, telegramUserID string, roundID int64) (string, error) {
+	var userID int64
+	err := s.db.QueryRow(ctx, `SELECT id FROM users WHERE telegram_id = $1`, telegramUserID).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errors.New("link your Telegram account first")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	round, err := s.ClaimPrize(ctx, userID, roundID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Prize from round #%d claimed. Check your inventory.", round.ID), nil
+}
+
+func (s *Service) expireClaims(ctx context.Context) error {
+	expired, err := s.lottery.ListExpiredClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, round := range expired {
+		if round.StreamSessionID == nil {
+			continue
+		}
+		session, err := s.getSession(ctx, *round.StreamSessionID)
+		if err != nil {
+			log.Printf("stream claim redraw failed: round=%d err=%v", round.ID, err)
+			continue
+		}
+		participants, err := s.ListPresentParticipants(ctx, session.ID)
+		if err != nil {
+			log.Printf("stream claim redraw failed: round=%d err=%v", round.ID, err)
+			continue
+		}
+
+		weighting := session.Weighting
+		var details struct {
+			PrizeName string             `json:"prize_name"`
+			Note      string             `json:"note"`
+			Weighting *lottery.Weighting `json:"weighting"`
+		}
+		_ = json.Unmarshal(round.Details, &details)
+		if details.Weighting != nil {
+			weighting = *details.Weighting
+		}
+		redrawn, err := s.lottery.RedrawExpiredClaim(ctx, round.ID, participants, session.drawOptions(weighting))
+		if err != nil {
+			log.Printf("stream claim redraw failed: round=%d err=%v", round.ID, err)
+			continue
+		}
+		if redrawn == nil {
+			continue
+		}
+		if redrawn.TriggerType == "manual" {
+			s.announceManualDraw(ctx, session, *redrawn, details.PrizeName, details.Note)
+		}
+		s.notifyClaim(ctx, session, *redrawn, details.PrizeName)
+	}
+	return nil
+}
+
+func (session Session) drawOptions(weighting lottery.Weighting) lottery.DrawOptions {
+	return lottery.DrawOptions{
+		Weighting:   weighting,
+		Limits:      session.WinLimits,
+		ClaimWindow: time.Duration(session.ClaimWindowMins) * time.Minute,
+	}
+}
+
+func (s *Service) notifyClaim(ctx context.Context, session Session, round lottery.Round, prizeName string) {
+	if s.bot == nil || round.ClaimStatus != lottery.ClaimPending || round.WinnerUserID == nil || round.ClaimExpiresAt == nil {
+		return
+	}
+
+	var telegramID string
+	if err := s.db.QueryRow(ctx, `SELECT COALESCE(telegram_id, '') FROM users WHERE id = $1`, *round.WinnerUserID).Scan(&telegramID); err != nil || telegramID == "" {
+		return
+	}
+	if prizeName == "" {
+		prizeName = "a prize"
+	}
+	message := fmt.Sprintf(
+		"You won %s in %s! Claim it before %s UTC or it will be redrawn.\nSend /claim %d here or open %s/simulator.html?invite=%s",
+		prizeName,
+		session.Title,
+		round.ClaimExpiresAt.UTC().Format("15:04"),
+		round.ID,
+		s.baseURL,
+		session.InviteCode,
+	)
+	_ = s.bot.SendMessage(ctx, telegramID, message)
+}
@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DelegateModerator  = "moderator"
+	DelegateCoStreamer = "co_streamer"
+)
+
+const (
+	permViewSession   = "view_session"
+	permManageRules   = "manage_rules"
+	permModerate      = "moderate"
+	permEndSession    = "end_session"
+	permManageSession = "manage_session"
+	permManageDraws   = "manage_draws"
+)
+
+var delegatePermissions = map[string][]string{
+	DelegateModerator:  {permViewSession, permManageRules, permModerate, permEndSession},
+	DelegateCoStreamer: {permViewSession, permManageRules, permModerate, permEndSession, permManageSession, permManageDraws},
+}
+
+type Delegate struct {
+	StreamerID       int64     `json:"streamer_id"`
+	StreamerUsername string    `json:"streamer_username,omitempty"`
+	UserID           int64     `json:"user_id"`
+	Username         string    `json:"username,omitempty"`
+	Role             string    `json:"role"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type AuditEntry struct {
+	ID              int64           `json:"id"`
+	StreamerID      int64           `json:"streamer_id"`
+	StreamSessionID *int64          `json:"stream_session_id,omitempty"`
+	ActorID         int64           `json:"actor_id"`
+	ActorUsername   string          `json:"actor_username"`
+	ActorRole       string          `json:"actor_role"`
+	Action          string          `json:"action"`
+	Details         json.RawMessage `json:"details"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+func (s *Service) GrantDelegate(ctx context.Context, streamerID, userID int64, role string) (Delegate, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		role = DelegateModerator
+	}
+	if _, ok := delegatePermissions[role]; !ok {
+		return Delegate{}, errors.New("role must be moderator or co_streamer")
+	}
+	if userID == streamerID {
+		return Delegate{}, errors.New("cannot delegate to yourself")
+	}
+
+	var d Delegate
+	err := s.db.QueryRow(ctx, `
+INSERT INTO stream_delegates (streamer_id, user_id, role)
+SELECT $1, u.id, $3
+FROM users u
+WHERE u.id = $2
+ON CONFLICT (streamer_id, user_id)
+DO UPDATE SET role = EXCLUDED.role
+RETURNING streamer_id, user_id, role, created_at
+`, streamerID, userID, role).Scan(&d.StreamerID, &d.UserID, &d.Role, &d.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Delegate{}, errors.New("user not found")
+	}
+	if err != nil {
+		return Delegate{}, err
+	}
+
+	s.recordAudit(ctx, streamerID, nil, streamerID, "owner", "grant_delegate", map[string]interface{}{"user_id": userID, "role": role})
+	return d, nil
+}
+
+func (s *Service) RevokeDelegate(ctx context.Context, streamerID, userID int64) error {
+	result, err := s.db.Exec(ctx, `DELETE FROM stream_delegates WHERE streamer_id = $1 AND user_id = $2`, streamerID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("delegate not found")
+	}
+	s.recordAudit(ctx, streamerID, nil, streamerID, "owner", "revoke_delegate", map[string]interface{}{"user_id": userID})
+	return nil
+}
+
+func (s *Service) ListDelegates(ctx context.Context, streamerID int64) ([]Delegate, error) {
+	return s.queryDelegates(ctx, `WHERE d.streamer_id = $1`, streamerID)
+}
+
+func (s *Service) ListDelegations(ctx context.Context, userID int64) ([]Delegate, error) {
+	return s.queryDelegates(ctx, `WHERE d.user_id = $1`, userID)
+}
+
+func (s *Service) queryDelegates(ctx context.Context, where string, id int64) ([]Delegate, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT d.streamer_id, COALESCE(su.username, su.telegram_username, ''), d.user_id, COALESCE(u.username, u.telegram_username, ''), d.role, d.created_at
+FROM stream_delegates d
+JOIN users su ON su.id = d.streamer_id
+JOIN users u ON u.id = d.user_id
+`+where+`
+ORDER BY d.created_at
+`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delegates := make([]Delegate, 0)
+	for rows.Next() {
+		var d Delegate
+		if err := rows.Scan(&d.StreamerID, &d.StreamerUsername, &d.UserID, &d.Username, &d.Role, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		delegates = append(delegates, d)
+	}
+	return delegates, rows.Err()
+}
+
+func (s *Service) ListAuditLog(ctx context.Context, streamerID int64, sessionID *int64, limit int) ([]AuditEntry, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT a.id, a.streamer_id, a.stream_session_id, a.actor_id, COALESCE(u.username, u.telegram_username, ''), a.actor_role, a.action, a.details, a.created_at
+FROM stream_audit_log a
+JOIN users u ON u.id = a.actor_id
+WHERE a.streamer_id = $1 AND ($2::bigint IS NULL OR a.stream_session_id = $2)
+ORDER BY a.created_at DESC
+LIMIT $3
+`, streamerID, sessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.StreamerID, &e.StreamSessionID, &e.ActorID, &e.ActorUsername, &e.ActorRole, &e.Action, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *Service) authorizeSession(ctx context.Context, actorID, sessionID int64, perm string) (Session, string, error) {
+	session, err := s.getSession(ctx, sessionID)
+	if err != nil {
+		return Session{}, "", err
+	}
+	if session.StreamerID == actorID {
+		return session, "owner", nil
+	}
+
+	var role string
+	err = s.db.QueryRow(ctx, `SELECT role FROM stream_delegates WHERE streamer_id = $1 AND user_id = $2`, session.StreamerID, actorID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Session{}, "", errors.New("not your stream session")
+	}
+	if err != nil {
+		return Session{}, "", err
+	}
+	for _, p := range delegatePermissions[role] {
+		if p == perm {
+			return session, role, nil
+		}
+	}
+	return Session{}, "", errors.New("your " + role + " role does not allow this action")
+}
+
+func (s *Service) recordAudit(ctx context.Context, streamerID int64, sessionID *int64, actorID int64, actorRole, action string, details map[string]interface{}) {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	payload, _ := json.Marshal(details)
+	_, _ = s.db.Exec(ctx, `
+INSERT INTO stream_audit_log (streamer_id, stream_session_id, actor_id, actor_role, action, details)
+VALUES ($1, $2, $3, $4, $5, $6)
+`, streamerID, sessionID, actorID, actorRole, action, payload)
+}
+
+func (s *Service) auditSession(ctx context.Context, session Session, actorID int64, actorRole, action string, details map[string]interface{}) {
+	s.recordAudit(ctx, session.StreamerID, &session.ID, actorID, actorRole, action, details)
+}
@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+)
+
+func (s *Service) DrawNow(ctx context.Context, actorID, sessionID int64, prizeType, prizeName string, prizeCents int64, note string, weighting *lottery.Weighting) (lottery.Round, error) {
+	prizeType = strings.ToLower(strings.TrimSpace(prizeType))
+	prizeName = strings.TrimSpace(prizeName)
+	note = strings.TrimSpace(note)
+	if prizeName == "" {
+		return lottery.Round{}, errors.New("prize_name is required")
+	}
+	if prizeType != "skin" && prizeType != "case" {
+		return lottery.Round{}, errors.New("prize_type must be skin or case")
+	}
+	if prizeCents < 0 {
+		return lottery.Round{}, errors.New("prize_cents cannot be negative")
+	}
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageDraws)
+	if err != nil {
+		return lottery.Round{}, err
+	}
+	opts := session.drawOptions(session.Weighting)
+	if weighting != nil {
+		opts.Weighting = *weighting
+	}
+	if session.Status != "active" {
+		return lottery.Round{}, errors.New("stream session is not active")
+	}
+
+	participants, err := s.ListPresentParticipants(ctx, session.ID)
+	if err != nil {
+		return lottery.Round{}, err
+	}
+	if len(participants) == 0 {
+		return lottery.Round{}, errors.New("no participants to draw from")
+	}
+
+	streamID := session.ID
+	deliver := s.prizeDeliverer(prizeType, prizeName, prizeCents, map[string]interface{}{
+		"stream_session_id": session.ID,
+		"trigger_type":      "manual",
+	})
+	round, err := s.lottery.TriggerForUsers(ctx, "manual", nil, &streamID, prizeCents, participants, map[string]interface{}{
+		"prize_type":   prizeType,
+		"prize_name":   prizeName,
+		"note":         note,
+		"triggered_by": actorID,
+	}, opts, deliver)
+	if err != nil {
+		return lottery.Round{}, err
+	}
+	if round == nil {
+		return lottery.Round{}, errors.New("no eligible participants to draw from")
+	}
+
+	s.auditSession(ctx, session, actorID, role, "manual_draw", map[string]interface{}{"lottery_round_id": round.ID, "prize_name": prizeName, "prize_cents": prizeCents, "note": note})
+
+	s.announceManualDraw(ctx, session, *round, prizeName, note)
+	s.notifyClaim(ctx, session, *round, prizeName)
+
+	return *round, nil
+}
+
+func (s *Service) announceManualDraw(ctx context.Context, session Session, round lottery.Round, prizeName, note string) {
+	if s.bot == nil || session.TelegramChatID == "" || round.WinnerUserID == nil {
+		return
+	}
+
+	var message string
+	if round.ClaimStatus == lottery.ClaimPending && round.ClaimExpiresAt != nil {
+		message = fmt.Sprintf("Manual draw in %s: a winner was picked for %s and has until %s UTC to claim it, or it will be redrawn.", session.Title, prizeName, round.ClaimExpiresAt.UTC().Format("15:04"))
+	} else {
+		var winner string
+		_ = s.db.QueryRow(ctx, `SELECT COALESCE(username, telegram_username, '') FROM users WHERE id = $1`, *round.WinnerUserID).Scan(&winner)
+		if winner == "" {
+			winner = fmt.Sprintf("user #%d", *round.WinnerUserID)
+		}
+		message = fmt.Sprintf("Manual draw in %s: %s wins %s!", session.Title, winner, prizeName)
+	}
+	if note != "" {
+		message += "\n" + note
+	}
+	_ = s.bot.SendMessage(ctx, session.TelegramChatID, message)
+}
@@ -0,0 +1,182 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type EligibilityPolicy struct {
+	RequireSteam          bool `json:"require_steam"`
+	MinAccountAgeDays     int  `json:"min_account_age_days"`
+	MinSteamLevel         int  `json:"min_steam_level"`
+	MinCS2Hours           int  `json:"min_cs2_hours"`
+	RequireTelegramMember bool `json:"require_telegram_member"`
+	MaxParticipants       int  `json:"max_participants"`
+}
+
+type EligibilityRejection struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type EligibilityError struct {
+	Reasons []EligibilityRejection
+}
+
+func (e *EligibilityError) Error() string {
+	messages := make([]string, 0, len(e.Reasons))
+	for _, reason := range e.Reasons {
+		messages = append(messages, reason.Message)
+	}
+	return "not eligible to join: " + strings.Join(messages, "; ")
+}
+
+func (s *Service) GetEligibilityPolicy(ctx context.Context, actorID, sessionID int64) (EligibilityPolicy, error) {
+	if _, _, err := s.authorizeSession(ctx, actorID, sessionID, permViewSession); err != nil {
+		return EligibilityPolicy{}, err
+	}
+	return loadEligibilityPolicy(ctx, s.db, sessionID)
+}
+
+func (s *Service) SetEligibilityPolicy(ctx context.Context, actorID, sessionID int64, policy EligibilityPolicy) (EligibilityPolicy, error) {
+	if policy.MinAccountAgeDays < 0 || policy.MinSteamLevel < 0 || policy.MinCS2Hours < 0 || policy.MaxParticipants < 0 {
+		return EligibilityPolicy{}, errors.New("eligibility thresholds cannot be negative")
+	}
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageSession)
+	if err != nil {
+		return EligibilityPolicy{}, err
+	}
+	if policy.RequireTelegramMember && session.TelegramChatID == "" {
+		return EligibilityPolicy{}, errors.New("require_telegram_member needs a telegram_chat_id on the session")
+	}
+	if (policy.MinSteamLevel > 0 || policy.MinCS2Hours > 0) && s.steam == nil {
+		return EligibilityPolicy{}, errors.New("min_steam_level and min_cs2_hours need STEAM_WEB_API_KEY to be configured")
+	}
+
+	_, err = s.db.Exec(ctx, `
+INSERT INTO stream_eligibility_policies (stream_session_id, require_steam, min_account_age_days, min_steam_level, min_cs2_hours, require_telegram_member, max_participants)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (stream_session_id)
+DO UPDATE SET require_steam = EXCLUDED.require_steam,
+              min_account_age_days = EXCLUDED.min_account_age_days,
+              min_steam_level = EXCLUDED.min_steam_level,
+              min_cs2_hours = EXCLUDED.min_cs2_hours,
+              require_telegram_member = EXCLUDED.require_telegram_member,
+              max_participants = EXCLUDED.max_participants,
+              updated_at = NOW()
+`, sessionID, policy.RequireSteam, policy.MinAccountAgeDays, policy.MinSteamLevel, policy.MinCS2Hours, policy.RequireTelegramMember, policy.MaxParticipants)
+	if err != nil {
+		return EligibilityPolicy{}, err
+	}
+	s.auditSession(ctx, session, actorID, role, "set_eligibility", map[string]interface{}{"policy": policy})
+	return policy, nil
+}
+
+func (s *Service) checkEligibility(ctx context.Context, session Session, userID int64) (EligibilityPolicy, error) {
+	policy, err := loadEligibilityPolicy(ctx, s.db, session.ID)
+	if err != nil {
+		return EligibilityPolicy{}, err
+	}
+
+	var steamID, telegramID string
+	var createdAt time.Time
+	if err := s.db.QueryRow(ctx, `
+SELECT COALESCE(steam_id, ''), COALESCE(telegram_id, ''), created_at
+FROM users
+WHERE id = $1
+`, userID).Scan(&steamID, &telegramID, &createdAt); err != nil {
+		return EligibilityPolicy{}, err
+	}
+
+	reasons := make([]EligibilityRejection, 0)
+	reject := func(code, message string) {
+		reasons = append(reasons, EligibilityRejection{Code: code, Message: message})
+	}
+
+	if policy.MinAccountAgeDays > 0 && time.Since(createdAt) < time.Duration(policy.MinAccountAgeDays)*24*time.Hour {
+		reject("account_too_new", fmt.Sprintf("account must be at least %d days old", policy.MinAccountAgeDays))
+	}
+
+	needsSteam := policy.RequireSteam || policy.MinSteamLevel > 0 || policy.MinCS2Hours > 0
+	if needsSteam && steamID == "" {
+		reject("steam_required", "link a Steam account to join this stream")
+	}
+	if steamID != "" && (policy.MinSteamLevel > 0 || policy.MinCS2Hours > 0) && s.steam == nil {
+		reject("steam_unverified", "Steam checks are not available right now")
+	}
+	if steamID != "" && policy.MinSteamLevel > 0 && s.steam != nil {
+		level, err := s.steam.GetSteamLevel(ctx, steamID)
+		switch {
+		case err != nil:
+			reject("steam_level_unavailable", "could not verify Steam level; make your Steam profile public")
+		case level < policy.MinSteamLevel:
+			reject("steam_level_too_low", fmt.Sprintf("Steam level %d or higher is required (yours is %d)", policy.MinSteamLevel, level))
+		}
+	}
+	if steamID != "" && policy.MinCS2Hours > 0 && s.steam != nil {
+		minutes, err := s.steam.GetCS2PlaytimeMinutes(ctx, steamID)
+		switch {
+		case err != nil:
+			reject("cs2_playtime_unavailable", "could not verify CS2 playtime; make your Steam game details public")
+		case minutes < int64(policy.MinCS2Hours)*60:
+			reject("cs2_playtime_too_low", fmt.Sprintf("at least %d hours of CS2 playtime are required (yours is %d)", policy.MinCS2Hours, minutes/60))
+		}
+	}
+
+	if policy.RequireTelegramMember {
+		if telegramID == "" {
+			reject("telegram_required", "link a Telegram account to join this stream")
+		} else if s.bot == nil {
+			reject("telegram_membership_unverified", "could not verify Telegram chat membership")
+		} else {
+			member, err := s.bot.IsChatMember(ctx, session.TelegramChatID, telegramID)
+			switch {
+			case err != nil:
+				reject("telegram_membership_unverified", "could not verify Telegram chat membership")
+			case !member:
+				reject("telegram_membership_required", "join the stream's Telegram chat first")
+			}
+		}
+	}
+
+	if len(reasons) > 0 {
+		return EligibilityPolicy{}, &EligibilityError{Reasons: reasons}
+	}
+	return policy, nil
+}
+
+func checkCapacity(ctx context.Context, tx pgx.Tx, sessionID int64, policy EligibilityPolicy) error {
+	if policy.MaxParticipants <= 0 {
+		return nil
+	}
+	var count int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM stream_participants WHERE stream_session_id = $1`, sessionID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= policy.MaxParticipants {
+		return &EligibilityError{Reasons: []EligibilityRejection{{
+			Code:    "session_full",
+			Message: fmt.Sprintf("giveaway pool is full (%d participants)", policy.MaxParticipants),
+		}}}
+	}
+	return nil
+}
+
+func loadEligibilityPolicy(ctx context.Context, q queryRower, sessionID int64) (EligibilityPolicy, error) {
+	var policy EligibilityPolicy
+	err := q.QueryRow(ctx, `
+SELECT require_steam, min_account_age_days, min_steam_level, min_cs2_hours, require_telegram_member, max_participants
+FROM stream_eligibility_policies
+WHERE stream_session_id = $1
+`, sessionID).Scan(&policy.RequireSteam, &policy.MinAccountAgeDays, &policy.MinSteamLevel, &policy.MinCS2Hours, &policy.RequireTelegramMember, &policy.MaxParticipants)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return EligibilityPolicy{}, nil
+	}
+	return policy, err
+}
@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Service) TouchActivity(ctx context.Context, streamerID int64) error {
+	_, err := s.db.Exec(ctx, `
+UPDATE stream_sessions
+SET last_activity_at = NOW()
+WHERE streamer_id = $1 AND status = 'active'
+`, streamerID)
+	return err
+}
+
+func (s *Service) expireIdleSessions(ctx context.Context) error {
+	if s.idleTimeout <= 0 {
+		return nil
+	}
+
+	rows, err := s.db.Query(ctx, `
+UPDATE stream_sessions ss
+SET status = 'ended', ended_at = NOW(), end_reason = 'idle_timeout'
+FROM users u
+WHERE u.id = ss.streamer_id
+  AND ss.status = 'active'
+  AND COALESCE(ss.last_activity_at, ss.started_at, ss.created_at) < NOW() - make_interval(secs => $1)
+RETURNING ss.id, ss.title, COALESCE(u.telegram_id, '')
+`, s.idleTimeout.Seconds())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type expired struct {
+		sessionID      int64
+		title          string
+		streamerChatID string
+	}
+	ended := make([]expired, 0)
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.sessionID, &e.title, &e.streamerChatID); err != nil {
+			return err
+		}
+		ended = append(ended, e)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range ended {
+		if session, err := s.getSession(ctx, e.sessionID); err == nil {
+			s.finishEndedSession(ctx, session)
+		}
+		if s.bot == nil || e.streamerChatID == "" {
+			continue
+		}
+		message := fmt.Sprintf("Session #%d %q was ended automatically: no game or viewer activity for %s.", e.sessionID, e.title, s.idleTimeout)
+		_ = s.bot.SendMessage(ctx, e.streamerChatID, message)
+	}
+	return nil
+}
+
+func endActiveSessions(ctx context.Context, tx pgx.Tx, streamerID int64, reason string) ([]Session, error) {
+	rows, err := tx.Query(ctx, `
+UPDATE stream_sessions
+SET status = 'ended', ended_at = NOW(), end_reason = $2
+WHERE streamer_id = $1 AND status = 'active'
+RETURNING `+sessionColumns, streamerID, reason)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ended := make([]Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		ended = append(ended, session)
+	}
+	return ended, rows.Err()
+}
@@ -1,14 +1,18 @@
 package stream
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/auth"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/httpx"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -24,14 +28,59 @@ type startRequest struct {
 	Title          string `json:"title"`
 	TelegramChatID string `json:"telegram_chat_id"`
 	SendToChat     bool   `json:"send_to_chat"`
+	Replace        bool   `json:"replace"`
+	TemplateID     *int64 `json:"template_id"`
 }
 
 type giveawayRuleRequest struct {
-	TriggerType string `json:"trigger_type"`
-	PrizeType   string `json:"prize_type"`
-	PrizeName   string `json:"prize_name"`
-	PrizeCents  int64  `json:"prize_cents"`
-	Enabled     bool   `json:"enabled"`
+	TriggerType string             `json:"trigger_type"`
+	PrizeType   string             `json:"prize_type"`
+	PrizeName   string             `json:"prize_name"`
+	PrizeCents  int64              `json:"prize_cents"`
+	Enabled     bool               `json:"enabled"`
+	Weighting   *lottery.Weighting `json:"weighting"`
+}
+
+type scheduleRequest struct {
+	Title           string    `json:"title"`
+	TelegramChatID  string    `json:"telegram_chat_id"`
+	TemplateID      *int64    `json:"template_id"`
+	ScheduledAt     time.Time `json:"scheduled_at"`
+	Recurrence      string    `json:"recurrence"`
+	ReminderMinutes *int      `json:"reminder_minutes"`
+}
+
+type ruleTemplateRequest struct {
+	Name  string         `json:"name"`
+	Rules []TemplateRule `json:"rules"`
+}
+
+type saveTemplateRequest struct {
+	Name string `json:"name"`
+}
+
+type inviteLinkRequest struct {
+	Label     string     `json:"label"`
+	MaxUses   int        `json:"max_uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type drawRequest struct {
+	PrizeType  string             `json:"prize_type"`
+	PrizeName  string             `json:"prize_name"`
+	PrizeCents int64              `json:"prize_cents"`
+	Note       string             `json:"note"`
+	Weighting  *lottery.Weighting `json:"weighting"`
+}
+
+type delegateRequest struct {
+	UserID int64  `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type banRequest struct {
+	Reason      string `json:"reason"`
+	AllSessions bool   `json:"all_sessions"`
 }
 
 func (h *Handler) Start(w http.ResponseWriter, r *http.Request) {
@@ -41,22 +90,486 @@ func (h *Handler) Start(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req startRequest
+	var req startRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	result, err := h.svc.StartSession(r.Context(), user.ID, req.Title, req.TelegramChatID, req.SendToChat, req.Replace, req.TemplateID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpx.WriteJSON(w, http.StatusCreated, result)
+}
+
+func (h *Handler) Schedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	reminderMinutes := 15
+	if req.ReminderMinutes != nil {
+		reminderMinutes = *req.ReminderMinutes
+	}
+
+	result, err := h.svc.ScheduleSession(r.Context(), user.ID, req.Title, req.TelegramChatID, req.TemplateID, req.ScheduledAt, req.Recurrence, reminderMinutes)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpx.WriteJSON(w, http.StatusCreated, result)
+}
+
+func (h *Handler) ListScheduled(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessions, err := h.svc.ListScheduled(r.Context(), user.ID)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list scheduled sessions")
+		return
+	}
+
+	scheduled := make([]StartResult, 0, len(sessions))
+	for _, session := range sessions {
+		scheduled = append(scheduled, h.svc.buildStartResult(session))
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"scheduled": scheduled})
+}
+
+func (h *Handler) CancelScheduled(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	session, err := h.svc.CancelScheduled(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"session": session})
+}
+
+func (h *Handler) End(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	session, summary, err := h.svc.EndSession(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := map[string]interface{}{"session": session}
+	if summary != nil {
+		response["summary"] = summary
+	}
+	httpx.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) Summary(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	summary, err := h.svc.GetSessionSummary(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"summary": summary})
+}
+
+func (h *Handler) ExportSummary(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	summary, err := h.svc.GetSessionSummary(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var bundle bytes.Buffer
+	if err := WriteSummaryCSVBundle(&bundle, summary); err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to export summary")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"session-%d-summary.zip\"", sessionID))
+	w.WriteHeader(http.StatusOK)
+	_, _ = bundle.WriteTo(w)
+}
+
+func (h *Handler) ActiveMine(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	session, err := h.svc.GetActiveByStreamer(r.Context(), user.ID)
+	if err != nil {
+		httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"session": nil})
+		return
+	}
+	result := h.svc.buildStartResult(session)
+	httpx.WriteJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) JoinInviteAuthenticated(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	inviteCode := chi.URLParam(r, "inviteCode")
+	session, err := h.svc.JoinByInvite(r.Context(), inviteCode, user.ID)
+	if err != nil {
+		var eligibilityErr *EligibilityError
+		if errors.As(err, &eligibilityErr) {
+			httpx.WriteJSON(w, http.StatusForbidden, map[string]interface{}{"error": err.Error(), "reasons": eligibilityErr.Reasons})
+			return
+		}
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"session": session, "joined": true})
+}
+
+func (h *Handler) ListParticipants(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	participants, err := h.svc.ListParticipantDetails(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	presence, err := h.svc.PresenceStats(r.Context(), sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to load presence")
+		return
+	}
+	userIDs := make([]int64, 0, len(participants))
+	for _, p := range participants {
+		userIDs = append(userIDs, p.UserID)
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"participants": participants, "user_ids": userIDs, "count": len(participants), "presence": presence})
+}
+
+func (h *Handler) CreateInviteLink(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	var req inviteLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	link, err := h.svc.CreateInviteLink(r.Context(), user.ID, sessionID, req.Label, req.MaxUses, req.ExpiresAt)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"invite": link})
+}
+
+func (h *Handler) ListInviteLinks(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	primary, links, err := h.svc.ListInviteLinks(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"primary": primary, "invites": links})
+}
+
+func (h *Handler) DeleteInviteLink(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	linkID, err := strconv.ParseInt(chi.URLParam(r, "linkID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid invite link id")
+		return
+	}
+	if err := h.svc.DeleteInviteLink(r.Context(), user.ID, sessionID, linkID); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"deleted": true})
+}
+
+func (h *Handler) KickParticipant(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if err := h.svc.KickParticipant(r.Context(), user.ID, sessionID, userID); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"kicked": true})
+}
+
+func (h *Handler) BanParticipant(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req banRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpx.Error(w, http.StatusBadRequest, "invalid json body")
+			return
+		}
+	}
+
+	ban, err := h.svc.BanParticipant(r.Context(), user.ID, sessionID, userID, req.Reason, req.AllSessions)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"ban": ban})
+}
+
+func (h *Handler) UnbanParticipant(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if err := h.svc.UnbanParticipant(r.Context(), user.ID, sessionID, userID); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"unbanned": true})
+}
+
+func (h *Handler) ListBans(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	bans, err := h.svc.ListBans(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"bans": bans})
+}
+
+func (h *Handler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	session, err := h.svc.Heartbeat(r.Context(), chi.URLParam(r, "inviteCode"), user.ID)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"session_id": session.ID, "present": true})
+}
+
+func (h *Handler) GetEligibility(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+	policy, err := h.svc.GetEligibilityPolicy(r.Context(), user.ID, sessionID)
+	if err != nil {
+		httpx.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"eligibility": policy})
+}
+
+func (h *Handler) SetEligibility(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	var req EligibilityPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	policy, err := h.svc.SetEligibilityPolicy(r.Context(), user.ID, sessionID, req)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"eligibility": policy})
+}
+
+func (h *Handler) SetWeighting(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	var req lottery.Weighting
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		httpx.Error(w, http.StatusBadRequest, "invalid json body")
 		return
 	}
 
-	result, err := h.svc.StartSession(r.Context(), user.ID, req.Title, req.TelegramChatID, req.SendToChat)
+	weighting, err := h.svc.SetSessionWeighting(r.Context(), user.ID, sessionID, req)
 	if err != nil {
 		httpx.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	httpx.WriteJSON(w, http.StatusCreated, result)
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"weighting": weighting})
 }
 
-func (h *Handler) End(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SetWinLimits(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
 		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
@@ -69,58 +582,83 @@ func (h *Handler) End(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := h.svc.EndSession(r.Context(), user.ID, sessionID)
+	var req lottery.WinLimits
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	limits, err := h.svc.SetSessionWinLimits(r.Context(), user.ID, sessionID, req)
 	if err != nil {
 		httpx.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"session": session})
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"win_limits": limits})
 }
 
-func (h *Handler) ActiveMine(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) SetClaimWindow(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
 		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	session, err := h.svc.GetActiveByStreamer(r.Context(), user.ID)
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
 	if err != nil {
-		httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"session": nil})
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
 		return
 	}
-	result := h.svc.buildStartResult(session)
-	httpx.WriteJSON(w, http.StatusOK, result)
+
+	var req struct {
+		ClaimWindowMinutes int `json:"claim_window_minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	minutes, err := h.svc.SetSessionClaimWindow(r.Context(), user.ID, sessionID, req.ClaimWindowMinutes)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"claim_window_minutes": minutes})
 }
 
-func (h *Handler) JoinInviteAuthenticated(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) MyClaims(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
 		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
-	inviteCode := chi.URLParam(r, "inviteCode")
-	session, err := h.svc.JoinByInvite(r.Context(), inviteCode, user.ID)
+
+	claims, err := h.svc.ListMyClaims(r.Context(), user.ID)
 	if err != nil {
-		httpx.Error(w, http.StatusBadRequest, err.Error())
+		httpx.Error(w, http.StatusInternalServerError, "failed to list claims")
 		return
 	}
-	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"session": session, "joined": true})
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"claims": claims})
 }
 
-func (h *Handler) ListParticipants(w http.ResponseWriter, r *http.Request) {
-	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+func (h *Handler) ClaimPrize(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	roundID, err := strconv.ParseInt(chi.URLParam(r, "roundID"), 10, 64)
 	if err != nil {
-		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		httpx.Error(w, http.StatusBadRequest, "invalid round id")
 		return
 	}
-	participants, err := h.svc.ListParticipants(r.Context(), sessionID)
+
+	round, err := h.svc.ClaimPrize(r.Context(), user.ID, roundID)
 	if err != nil {
-		httpx.Error(w, http.StatusInternalServerError, "failed to list participants")
+		httpx.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"user_ids": participants, "count": len(participants)})
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"round": round})
 }
 
 func (h *Handler) AddGiveawayRule(w http.ResponseWriter, r *http.Request) {
@@ -142,7 +680,7 @@ func (h *Handler) AddGiveawayRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rule, err := h.svc.AddGiveawayRule(r.Context(), user.ID, sessionID, req.TriggerType, req.PrizeType, req.PrizeName, req.PrizeCents)
+	rule, err := h.svc.AddGiveawayRule(r.Context(), user.ID, sessionID, req.TriggerType, req.PrizeType, req.PrizeName, req.PrizeCents, req.Weighting)
 	if err != nil {
 		httpx.Error(w, http.StatusBadRequest, err.Error())
 		return
@@ -151,15 +689,48 @@ func (h *Handler) AddGiveawayRule(w http.ResponseWriter, r *http.Request) {
 	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"rule": rule})
 }
 
+func (h *Handler) DrawNow(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	var req drawRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	round, err := h.svc.DrawNow(r.Context(), user.ID, sessionID, req.PrizeType, req.PrizeName, req.PrizeCents, req.Note, req.Weighting)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"round": round})
+}
+
 func (h *Handler) ListGiveawayRules(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
 	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
 	if err != nil {
 		httpx.Error(w, http.StatusBadRequest, "invalid session id")
 		return
 	}
-	rules, err := h.svc.ListGiveawayRules(r.Context(), sessionID)
+	rules, err := h.svc.ListGiveawayRules(r.Context(), user.ID, sessionID)
 	if err != nil {
-		httpx.Error(w, http.StatusInternalServerError, "failed to list giveaway rules")
+		httpx.Error(w, http.StatusForbidden, err.Error())
 		return
 	}
 	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"rules": rules})
@@ -193,7 +764,7 @@ func (h *Handler) UpdateGiveawayRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rule, err := h.svc.UpdateGiveawayRule(r.Context(), user.ID, sessionID, ruleID, req.TriggerType, req.PrizeType, req.PrizeName, req.PrizeCents, req.Enabled)
+	rule, err := h.svc.UpdateGiveawayRule(r.Context(), user.ID, sessionID, ruleID, req.TriggerType, req.PrizeType, req.PrizeName, req.PrizeCents, req.Enabled, req.Weighting)
 	if err != nil {
 		httpx.Error(w, http.StatusBadRequest, err.Error())
 		return
@@ -226,8 +797,140 @@ func (h *Handler) DeleteGiveawayRule(w http.ResponseWriter, r *http.Request) {
 	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"deleted": true})
 }
 
+func (h *Handler) ListRuleTemplates(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	templates, err := h.svc.ListRuleTemplates(r.Context(), user.ID)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list rule templates")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"templates": templates})
+}
+
+func (h *Handler) CreateRuleTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req ruleTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	tpl, err := h.svc.CreateRuleTemplate(r.Context(), user.ID, req.Name, req.Rules)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"template": tpl})
+}
+
+func (h *Handler) GetRuleTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	templateID, err := strconv.ParseInt(chi.URLParam(r, "templateID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	tpl, err := h.svc.GetRuleTemplate(r.Context(), user.ID, templateID)
+	if err != nil {
+		httpx.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"template": tpl})
+}
+
+func (h *Handler) UpdateRuleTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	templateID, err := strconv.ParseInt(chi.URLParam(r, "templateID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	var req ruleTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	tpl, err := h.svc.UpdateRuleTemplate(r.Context(), user.ID, templateID, req.Name, req.Rules)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"template": tpl})
+}
+
+func (h *Handler) DeleteRuleTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	templateID, err := strconv.ParseInt(chi.URLParam(r, "templateID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	if err := h.svc.DeleteRuleTemplate(r.Context(), user.ID, templateID); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"deleted": true})
+}
+
+func (h *Handler) SaveSessionAsTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	var req saveTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	tpl, err := h.svc.SaveSessionAsTemplate(r.Context(), user.ID, sessionID, req.Name)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"template": tpl})
+}
+
 func (h *Handler) InviteLanding(w http.ResponseWriter, r *http.Request) {
 	inviteCode := chi.URLParam(r, "inviteCode")
+	h.svc.RecordInviteClick(r.Context(), inviteCode)
 	query := r.URL.Query()
 	target := fmt.Sprintf("/simulator.html?invite=%s", inviteCode)
 	if token := query.Get("token"); token != "" {
@@ -238,3 +941,111 @@ func (h *Handler) InviteLanding(w http.ResponseWriter, r *http.Request) {
 	}
 	http.Redirect(w, r, target, http.StatusFound)
 }
+
+func (h *Handler) GrantDelegate(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req delegateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+
+	delegate, err := h.svc.GrantDelegate(r.Context(), user.ID, req.UserID, req.Role)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusCreated, map[string]interface{}{"delegate": delegate})
+}
+
+func (h *Handler) ListDelegates(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	delegates, err := h.svc.ListDelegates(r.Context(), user.ID)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list delegates")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"delegates": delegates})
+}
+
+func (h *Handler) RevokeDelegate(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		httpx.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if err := h.svc.RevokeDelegate(r.Context(), user.ID, userID); err != nil {
+		httpx.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"revoked": true})
+}
+
+func (h *Handler) ListDelegations(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	delegations, err := h.svc.ListDelegations(r.Context(), user.ID)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list delegations")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"delegations": delegations})
+}
+
+func (h *Handler) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	query := r.URL.Query()
+	var sessionID *int64
+	if raw := query.Get("session_id"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			httpx.Error(w, http.StatusBadRequest, "invalid session id")
+			return
+		}
+		sessionID = &parsed
+	}
+	limit := 50
+	if raw := query.Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil {
+			limit = parsed
+		}
+	}
+
+	entries, err := h.svc.ListAuditLog(r.Context(), user.ID, sessionID, limit)
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to list audit log")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+}
+
+func (h *Handler) ReconcilePrizes(w http.ResponseWriter, r *http.Request) {
+	report, err := h.svc.ReconcilePrizes(r.Context())
+	if err != nil {
+		httpx.Error(w, http.StatusInternalServerError, "failed to reconcile prizes")
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]interface{}{"report": report})
+}
@@ -0,0 +1,211 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type InviteLink struct {
+	ID               int64      `json:"id"`
+	StreamSessionID  int64      `json:"stream_session_id"`
+	Code             string     `json:"code"`
+	Label            string     `json:"label"`
+	MaxUses          int        `json:"max_uses"`
+	Uses             int        `json:"uses"`
+	Clicks           int        `json:"clicks"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	InviteURL        string     `json:"invite_url"`
+	TelegramDeepLink string     `json:"telegram_deep_link,omitempty"`
+}
+
+type PrimaryInvite struct {
+	Code      string `json:"code"`
+	InviteURL string `json:"invite_url"`
+	Joins     int    `json:"joins"`
+}
+
+const inviteLinkColumns = `id, stream_session_id, code, label, max_uses, uses, clicks, expires_at, revoked_at, created_at`
+
+func (s *Service) CreateInviteLink(ctx context.Context, actorID, sessionID int64, label string, maxUses int, expiresAt *time.Time) (InviteLink, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return InviteLink{}, errors.New("label is required")
+	}
+	if maxUses < 0 {
+		return InviteLink{}, errors.New("max_uses cannot be negative")
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return InviteLink{}, errors.New("expires_at must be in the future")
+	}
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageSession)
+	if err != nil {
+		return InviteLink{}, err
+	}
+	if session.Status != "active" && session.Status != "scheduled" {
+		return InviteLink{}, errors.New("stream session is not active")
+	}
+
+	code, err := generateInviteCode(12)
+	if err != nil {
+		return InviteLink{}, err
+	}
+
+	link, err := scanInviteLink(s.db.QueryRow(ctx, `
+INSERT INTO stream_invite_links (stream_session_id, code, label, max_uses, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING `+inviteLinkColumns, sessionID, code, label, maxUses, expiresAt))
+	if err != nil {
+		return InviteLink{}, err
+	}
+	s.auditSession(ctx, session, actorID, role, "create_invite_link", map[string]interface{}{"invite_link_id": link.ID, "label": link.Label})
+	return s.decorateInviteLink(link), nil
+}
+
+func (s *Service) ListInviteLinks(ctx context.Context, actorID, sessionID int64) (PrimaryInvite, []InviteLink, error) {
+	session, _, err := s.authorizeSession(ctx, actorID, sessionID, permManageSession)
+	if err != nil {
+		return PrimaryInvite{}, nil, err
+	}
+
+	primary := PrimaryInvite{
+		Code:      session.InviteCode,
+		InviteURL: fmt.Sprintf("%s/invite/%s", s.baseURL, session.InviteCode),
+	}
+	if err := s.db.QueryRow(ctx, `
+SELECT COUNT(*)
+FROM stream_participants
+WHERE stream_session_id = $1 AND invite_link_id IS NULL
+`, sessionID).Scan(&primary.Joins); err != nil {
+		return PrimaryInvite{}, nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT `+inviteLinkColumns+`
+FROM stream_invite_links
+WHERE stream_session_id = $1
+ORDER BY created_at
+`, sessionID)
+	if err != nil {
+		return PrimaryInvite{}, nil, err
+	}
+	defer rows.Close()
+
+	links := make([]InviteLink, 0)
+	for rows.Next() {
+		link, err := scanInviteLink(rows)
+		if err != nil {
+			return PrimaryInvite{}, nil, err
+		}
+		links = append(links, s.decorateInviteLink(link))
+	}
+	return primary, links, rows.Err()
+}
+
+func (s *Service) DeleteInviteLink(ctx context.Context, actorID, sessionID, linkID int64) error {
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageSession)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(ctx, `
+UPDATE stream_invite_links
+SET revoked_at = NOW()
+WHERE id = $1 AND stream_session_id = $2 AND revoked_at IS NULL
+`, linkID, sessionID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("invite link not found")
+	}
+	s.auditSession(ctx, session, actorID, role, "delete_invite_link", map[string]interface{}{"invite_link_id": linkID})
+	return nil
+}
+
+func (s *Service) RecordInviteClick(ctx context.Context, code string) {
+	_, _ = s.db.Exec(ctx, `UPDATE stream_invite_links SET clicks = clicks + 1 WHERE code = $1 AND revoked_at IS NULL`, strings.TrimSpace(code))
+}
+
+func (s *Service) decorateInviteLink(link InviteLink) InviteLink {
+	link.InviteURL = fmt.Sprintf("%s/invite/%s", s.baseURL, link.Code)
+	if s.botUsername != "" {
+		link.TelegramDeepLink = fmt.Sprintf("https://t.me/%s?start=invite_%s", s.botUsername, link.Code)
+	}
+	return link
+}
+
+func resolveInvite(ctx context.Context, q queryRower, code string) (Session, *InviteLink, error) {
+	session, err := scanSession(q.QueryRow(ctx, `
+SELECT `+sessionColumns+`
+FROM stream_sessions
+WHERE invite_code = $1
+`, code))
+	if err == nil {
+		return session, nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Session{}, nil, err
+	}
+
+	link, err := scanInviteLink(q.QueryRow(ctx, `
+SELECT `+inviteLinkColumns+`
+FROM stream_invite_links
+WHERE code = $1
+`, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Session{}, nil, errors.New("invite code not found")
+	}
+	if err != nil {
+		return Session{}, nil, err
+	}
+
+	session, err = scanSession(q.QueryRow(ctx, `
+SELECT `+sessionColumns+`
+FROM stream_sessions
+WHERE id = $1
+`, link.StreamSessionID))
+	if err != nil {
+		return Session{}, nil, err
+	}
+	return session, &link, nil
+}
+
+func claimInviteLink(ctx context.Context, tx pgx.Tx, linkID int64) error {
+	var maxUses, uses int
+	var expiresAt, revokedAt *time.Time
+	err := tx.QueryRow(ctx, `
+SELECT max_uses, uses, expires_at, revoked_at
+FROM stream_invite_links
+WHERE id = $1
+FOR UPDATE
+`, linkID).Scan(&maxUses, &uses, &expiresAt, &revokedAt)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && revokedAt != nil) {
+		return errors.New("invite link has been revoked")
+	}
+	if err != nil {
+		return err
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return errors.New("invite link has expired")
+	}
+	if maxUses > 0 && uses >= maxUses {
+		return errors.New("invite link has reached its maximum uses")
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE stream_invite_links SET uses = uses + 1 WHERE id = $1`, linkID)
+	return err
+}
+
+func scanInviteLink(row pgx.Row) (InviteLink, error) {
+	var link InviteLink
+	err := row.Scan(&link.ID, &link.StreamSessionID, &link.Code, &link.Label, &link.MaxUses, &link.Uses, &link.Clicks, &link.ExpiresAt, &link.RevokedAt, &link.CreatedAt)
+	return link, err
+}
@@ -0,0 +1,229 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Participant struct {
+	UserID           int64     `json:"user_id"`
+	Username         string    `json:"username"`
+	SteamID          string    `json:"steam_id,omitempty"`
+	TelegramUsername string    `json:"telegram_username,omitempty"`
+	JoinedAt         time.Time `json:"joined_at"`
+	LastSeenAt       time.Time `json:"last_seen_at"`
+	Present          bool      `json:"present"`
+	Weight           int64     `json:"weight"`
+	Wins             int       `json:"wins"`
+	WonCents         int64     `json:"won_cents"`
+	InviteLinkID     *int64    `json:"invite_link_id,omitempty"`
+	InviteLabel      string    `json:"invite_label,omitempty"`
+}
+
+type StreamBan struct {
+	ID              int64     `json:"id"`
+	StreamerID      int64     `json:"streamer_id"`
+	UserID          int64     `json:"user_id"`
+	Username        string    `json:"username"`
+	StreamSessionID *int64    `json:"stream_session_id,omitempty"`
+	Reason          string    `json:"reason"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (s *Service) ListParticipantDetails(ctx context.Context, actorID, sessionID int64) ([]Participant, error) {
+	session, _, err := s.authorizeSession(ctx, actorID, sessionID, permModerate)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT sp.user_id,
+       COALESCE(u.username, u.telegram_username, ''),
+       COALESCE(u.steam_id, ''),
+       COALESCE(u.telegram_username, ''),
+       sp.joined_at,
+       sp.last_seen_at,
+       $2::float8 <= 0 OR sp.last_seen_at > NOW() - make_interval(secs => $2),
+       COUNT(lr.id),
+       COALESCE(SUM(lr.prize_cents), 0),
+       sp.invite_link_id,
+       COALESCE(il.label, '')
+FROM stream_participants sp
+JOIN users u ON u.id = sp.user_id
+LEFT JOIN stream_invite_links il ON il.id = sp.invite_link_id
+LEFT JOIN lottery_rounds lr ON lr.stream_session_id = sp.stream_session_id AND lr.winner_user_id = sp.user_id AND lr.claim_status IS DISTINCT FROM 'expired'
+WHERE sp.stream_session_id = $1
+GROUP BY sp.user_id, u.username, u.telegram_username, u.steam_id, sp.joined_at, sp.last_seen_at, sp.invite_link_id, il.label
+ORDER BY sp.joined_at DESC
+`, sessionID, s.presenceTimeout.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := make([]Participant, 0)
+	userIDs := make([]int64, 0)
+	for rows.Next() {
+		var p Participant
+		if err := rows.Scan(&p.UserID, &p.Username, &p.SteamID, &p.TelegramUsername, &p.JoinedAt, &p.LastSeenAt, &p.Present, &p.Wins, &p.WonCents, &p.InviteLinkID, &p.InviteLabel); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+		userIDs = append(userIDs, p.UserID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	weights, err := s.lottery.CandidateWeights(ctx, userIDs, &session.ID, session.Weighting)
+	if err != nil {
+		return nil, err
+	}
+	for i := range participants {
+		participants[i].Weight = weights[participants[i].UserID]
+	}
+	return participants, nil
+}
+
+func (s *Service) KickParticipant(ctx context.Context, actorID, sessionID, userID int64) error {
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permModerate)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(ctx, `DELETE FROM stream_participants WHERE stream_session_id = $1 AND user_id = $2`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("participant not found")
+	}
+	s.auditSession(ctx, session, actorID, role, "kick_participant", map[string]interface{}{"user_id": userID})
+	return nil
+}
+
+func (s *Service) BanParticipant(ctx context.Context, actorID, sessionID, userID int64, reason string, allSessions bool) (StreamBan, error) {
+	if userID == actorID {
+		return StreamBan{}, errors.New("cannot ban yourself")
+	}
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permModerate)
+	if err != nil {
+		return StreamBan{}, err
+	}
+	if userID == session.StreamerID {
+		return StreamBan{}, errors.New("cannot ban the streamer")
+	}
+	if role != "owner" {
+		var delegate bool
+		if err := s.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM stream_delegates WHERE streamer_id = $1 AND user_id = $2)`, session.StreamerID, userID).Scan(&delegate); err != nil {
+			return StreamBan{}, err
+		}
+		if delegate {
+			return StreamBan{}, errors.New("only the streamer can ban a moderator or co-streamer")
+		}
+	}
+
+	var scope *int64
+	if !allSessions {
+		scope = &sessionID
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return StreamBan{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var ban StreamBan
+	err = tx.QueryRow(ctx, `
+INSERT INTO stream_bans (streamer_id, user_id, stream_session_id, reason)
+SELECT $1, u.id, $3, $4
+FROM users u
+WHERE u.id = $2
+ON CONFLICT (streamer_id, user_id, (COALESCE(stream_session_id, 0)))
+DO UPDATE SET reason = EXCLUDED.reason
+RETURNING id, streamer_id, user_id, stream_session_id, reason, created_at
+`, session.StreamerID, userID, scope, strings.TrimSpace(reason)).Scan(&ban.ID, &ban.StreamerID, &ban.UserID, &ban.StreamSessionID, &ban.Reason, &ban.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return StreamBan{}, errors.New("user not found")
+	}
+	if err != nil {
+		return StreamBan{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM stream_participants WHERE stream_session_id = $1 AND user_id = $2`, sessionID, userID); err != nil {
+		return StreamBan{}, err
+	}
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(username, telegram_username, '') FROM users WHERE id = $1`, userID).Scan(&ban.Username); err != nil {
+		return StreamBan{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return StreamBan{}, err
+	}
+	s.auditSession(ctx, session, actorID, role, "ban_participant", map[string]interface{}{"user_id": userID, "all_sessions": allSessions, "reason": ban.Reason})
+	return ban, nil
+}
+
+func (s *Service) UnbanParticipant(ctx context.Context, actorID, sessionID, userID int64) error {
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permModerate)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(ctx, `
+DELETE FROM stream_bans
+WHERE streamer_id = $1 AND user_id = $2 AND (stream_session_id = $3 OR stream_session_id IS NULL)
+`, session.StreamerID, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("ban not found")
+	}
+	s.auditSession(ctx, session, actorID, role, "unban_participant", map[string]interface{}{"user_id": userID})
+	return nil
+}
+
+func (s *Service) ListBans(ctx context.Context, actorID, sessionID int64) ([]StreamBan, error) {
+	session, _, err := s.authorizeSession(ctx, actorID, sessionID, permModerate)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT b.id, b.streamer_id, b.user_id, COALESCE(u.username, u.telegram_username, ''), b.stream_session_id, b.reason, b.created_at
+FROM stream_bans b
+JOIN users u ON u.id = b.user_id
+WHERE b.streamer_id = $1 AND (b.stream_session_id = $2 OR b.stream_session_id IS NULL)
+ORDER BY b.created_at DESC
+`, session.StreamerID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := make([]StreamBan, 0)
+	for rows.Next() {
+		var b StreamBan
+		if err := rows.Scan(&b.ID, &b.StreamerID, &b.UserID, &b.Username, &b.StreamSessionID, &b.Reason, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
+
+func isBanned(ctx context.Context, q queryRower, session Session, userID int64) (bool, error) {
+	var banned bool
+	err := q.QueryRow(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM stream_bans
+	WHERE streamer_id = $1 AND user_id = $2 AND (stream_session_id = $3 OR stream_session_id IS NULL)
+)
+`, session.StreamerID, userID, session.ID).Scan(&banned)
+	return banned, err
+}
@@ -0,0 +1,177 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxPrizeDeliveryAttempts = 5
+
+type ReconcileReport struct {
+	Checked int `json:"checked"`
+	Linked  int `json:"linked"`
+	Granted int `json:"granted"`
+	Failed  int `json:"failed"`
+	GaveUp  int `json:"gave_up"`
+}
+
+func (s *Service) prizeDeliverer(prizeType, prizeName string, prizeCents int64, metadata map[string]interface{}) lottery.PrizeDeliverer {
+	if s.inventory == nil {
+		return nil
+	}
+	return func(ctx context.Context, tx pgx.Tx, round lottery.Round) (int64, error) {
+		if round.WinnerUserID == nil {
+			return 0, errors.New("round has no winner")
+		}
+		meta := make(map[string]interface{}, len(metadata)+2)
+		for k, v := range metadata {
+			meta[k] = v
+		}
+		meta["lottery_round_id"] = round.ID
+		meta["price_cents"] = prizeCents
+
+		item, err := s.inventory.GrantItemTx(ctx, tx, *round.WinnerUserID, prizeType, prizeName, "restricted", "stream_giveaway", nil, meta)
+		if err != nil {
+			return 0, err
+		}
+		return item.ID, nil
+	}
+}
+
+func (s *Service) ReconcilePrizes(ctx context.Context) (ReconcileReport, error) {
+	var report ReconcileReport
+	if s.inventory == nil {
+		return report, nil
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT id, winner_user_id, stream_session_id, trigger_type, prize_cents,
+       COALESCE(details->>'prize_type', 'case'), details->>'prize_name', COALESCE(details->>'rule_id', ''), created_at
+FROM lottery_rounds
+WHERE prize_delivered_at IS NULL
+  AND COALESCE(claim_status, 'claimed') = 'claimed'
+  AND winner_user_id IS NOT NULL
+  AND stream_session_id IS NOT NULL
+  AND COALESCE(details->>'prize_name', '') <> ''
+  AND created_at < NOW() - INTERVAL '1 minute'
+  AND prize_delivery_attempts < $1
+ORDER BY prize_delivery_attempts, id
+LIMIT 100
+`, maxPrizeDeliveryAttempts)
+	if err != nil {
+		return report, err
+	}
+
+	type pending struct {
+		round     lottery.Round
+		prizeType string
+		prizeName string
+		ruleID    string
+	}
+	rounds := make([]pending, 0)
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.round.ID, &p.round.WinnerUserID, &p.round.StreamSessionID, &p.round.TriggerType, &p.round.PrizeCents, &p.prizeType, &p.prizeName, &p.ruleID, &p.round.CreatedAt); err != nil {
+			rows.Close()
+			return report, err
+		}
+		rounds = append(rounds, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	for _, p := range rounds {
+		report.Checked++
+		granted, err := s.reconcileRound(ctx, p.round, p.prizeType, p.prizeName, p.ruleID)
+		switch {
+		case err != nil:
+			report.Failed++
+			log.Printf("prize reconciliation failed: round=%d err=%v", p.round.ID, err)
+			var attempts int
+			if scanErr := s.db.QueryRow(ctx, `
+UPDATE lottery_rounds
+SET prize_delivery_attempts = prize_delivery_attempts + 1, prize_delivery_error = $2
+WHERE id = $1
+RETURNING prize_delivery_attempts
+`, p.round.ID, err.Error()).Scan(&attempts); scanErr != nil {
+				log.Printf("record prize delivery attempt failed: round=%d err=%v", p.round.ID, scanErr)
+			} else if attempts >= maxPrizeDeliveryAttempts {
+				report.GaveUp++
+				log.Printf("prize reconciliation gave up: round=%d attempts=%d", p.round.ID, attempts)
+			}
+		case granted:
+			report.Granted++
+		default:
+			report.Linked++
+		}
+	}
+	return report, nil
+}
+
+func (s *Service) reconcileRound(ctx context.Context, round lottery.Round, prizeType, prizeName, ruleID string) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var pending bool
+	if err := tx.QueryRow(ctx, `SELECT prize_delivered_at IS NULL FROM lottery_rounds WHERE id = $1 FOR UPDATE`, round.ID).Scan(&pending); err != nil {
+		return false, err
+	}
+	if !pending {
+		return false, nil
+	}
+
+	var itemID int64
+	err = tx.QueryRow(ctx, `
+SELECT i.id
+FROM inventory_items i
+WHERE i.user_id = $1
+  AND i.source = 'stream_giveaway'
+  AND i.name = $2
+  AND (
+        i.metadata->>'lottery_round_id' = $3
+     OR (i.metadata->>'lottery_round_id' IS NULL
+         AND i.metadata->>'stream_session_id' = $4
+         AND COALESCE(i.metadata->>'rule_id', '') = $5
+         AND i.created_at BETWEEN $6 AND $6 + INTERVAL '5 minutes')
+  )
+  AND NOT EXISTS (SELECT 1 FROM lottery_rounds o WHERE o.prize_item_id = i.id)
+ORDER BY i.created_at
+LIMIT 1
+`, *round.WinnerUserID, prizeName, strconv.FormatInt(round.ID, 10), strconv.FormatInt(*round.StreamSessionID, 10), ruleID, round.CreatedAt).Scan(&itemID)
+
+	granted := false
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		metadata := map[string]interface{}{
+			"stream_session_id": *round.StreamSessionID,
+			"trigger_type":      round.TriggerType,
+			"reconciled_at":     time.Now().UTC(),
+		}
+		if ruleID != "" {
+			metadata["rule_id"] = ruleID
+		}
+		itemID, err = s.prizeDeliverer(prizeType, prizeName, round.PrizeCents, metadata)(ctx, tx, round)
+		if err != nil {
+			return false, err
+		}
+		granted = true
+	case err != nil:
+		return false, err
+	}
+
+	if err := lottery.MarkPrizeDelivered(ctx, tx, round.ID, itemID); err != nil {
+		return false, err
+	}
+	return granted, tx.Commit(ctx)
+}
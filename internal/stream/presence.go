@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+)
+
+type PresenceStats struct {
+	Total          int     `json:"total"`
+	Present        int     `json:"present"`
+	Inactive       int     `json:"inactive"`
+	PresentUserIDs []int64 `json:"present_user_ids"`
+	TimeoutSeconds int64   `json:"timeout_seconds"`
+}
+
+func (s *Service) Heartbeat(ctx context.Context, inviteCode string, userID int64) (Session, error) {
+	inviteCode = strings.TrimSpace(inviteCode)
+	if inviteCode == "" {
+		return Session{}, errors.New("invite code is required")
+	}
+
+	session, _, err := resolveInvite(ctx, s.db, inviteCode)
+	if err != nil || session.Status != "active" {
+		return Session{}, errors.New("stream session is not active")
+	}
+
+	result, err := s.db.Exec(ctx, `
+UPDATE stream_participants
+SET last_seen_at = NOW()
+WHERE stream_session_id = $1 AND user_id = $2
+`, session.ID, userID)
+	if err != nil {
+		return Session{}, err
+	}
+	if result.RowsAffected() == 0 {
+		return Session{}, errors.New("join the stream first")
+	}
+
+	_, _ = s.db.Exec(ctx, `UPDATE stream_sessions SET last_activity_at = NOW() WHERE id = $1`, session.ID)
+	_ = s.lottery.RecordActivity(ctx, userID, &session.ID, lottery.ActivityPresence)
+	return session, nil
+}
+
+func (s *Service) ListPresentParticipants(ctx context.Context, sessionID int64) ([]int64, error) {
+	if s.presenceTimeout <= 0 {
+		return s.ListParticipants(ctx, sessionID)
+	}
+
+	rows, err := s.db.Query(ctx, `
+SELECT user_id
+FROM stream_participants
+WHERE stream_session_id = $1 AND last_seen_at > NOW() - make_interval(secs => $2)
+ORDER BY joined_at DESC
+`, sessionID, s.presenceTimeout.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+	return result, rows.Err()
+}
+
+func (s *Service) PresenceStats(ctx context.Context, sessionID int64) (PresenceStats, error) {
+	all, err := s.ListParticipants(ctx, sessionID)
+	if err != nil {
+		return PresenceStats{}, err
+	}
+	present, err := s.ListPresentParticipants(ctx, sessionID)
+	if err != nil {
+		return PresenceStats{}, err
+	}
+	return PresenceStats{
+		Total:          len(all),
+		Present:        len(present),
+		Inactive:       len(all) - len(present),
+		PresentUserIDs: present,
+		TimeoutSeconds: int64(s.presenceTimeout.Seconds()),
+	}, nil
+}
+
+func (s *Service) RecordChatMessage(ctx context.Context, chatID, telegramUserID string) {
+	var sessionID, userID int64
+	err := s.db.QueryRow(ctx, `
+SELECT ss.id, u.id
+FROM stream_sessions ss
+JOIN stream_participants sp ON sp.stream_session_id = ss.id
+JOIN users u ON u.id = sp.user_id
+WHERE ss.telegram_chat_id = $1 AND ss.status = 'active' AND u.telegram_id = $2
+LIMIT 1
+`, chatID, telegramUserID).Scan(&sessionID, &userID)
+	if err != nil {
+		return
+	}
+	_ = s.lottery.RecordActivity(ctx, userID, &sessionID, lottery.ActivityChatMessage)
+}
@@ -0,0 +1,338 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (s *Service) ScheduleSession(ctx context.Context, streamerID int64, title, telegramChatID string, templateID *int64, scheduledAt time.Time, recurrence string, reminderMinutes int) (StartResult, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = "LiveDrop Session"
+	}
+	if scheduledAt.IsZero() || !scheduledAt.After(time.Now()) {
+		return StartResult{}, errors.New("scheduled_at must be in the future")
+	}
+	recurrence = strings.ToLower(strings.TrimSpace(recurrence))
+	if recurrence == "" {
+		recurrence = "none"
+	}
+	if _, ok := recurrenceInterval(recurrence); !ok && recurrence != "none" {
+		return StartResult{}, errors.New("recurrence must be none, daily or weekly")
+	}
+	if reminderMinutes < 0 || reminderMinutes > 24*60 {
+		return StartResult{}, errors.New("reminder_minutes must be between 0 and 1440")
+	}
+	if templateID != nil {
+		if _, err := s.GetRuleTemplate(ctx, streamerID, *templateID); err != nil {
+			return StartResult{}, err
+		}
+	}
+
+	session, err := insertScheduledSession(ctx, s.db, Session{
+		StreamerID:      streamerID,
+		Title:           title,
+		TelegramChatID:  telegramChatID,
+		ScheduledAt:     &scheduledAt,
+		TemplateID:      templateID,
+		Recurrence:      recurrence,
+		ReminderMinutes: reminderMinutes,
+	})
+	if err != nil {
+		return StartResult{}, err
+	}
+	return s.buildStartResult(session), nil
+}
+
+func (s *Service) ListScheduled(ctx context.Context, streamerID int64) ([]Session, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT `+sessionColumns+`
+FROM stream_sessions
+WHERE streamer_id = $1 AND status = 'scheduled'
+ORDER BY scheduled_at
+`, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *Service) CancelScheduled(ctx context.Context, streamerID, sessionID int64) (Session, error) {
+	session, err := scanSession(s.db.QueryRow(ctx, `
+UPDATE stream_sessions
+SET status = 'cancelled', ended_at = NOW(), end_reason = 'cancelled'
+WHERE id = $1 AND streamer_id = $2 AND status = 'scheduled'
+RETURNING `+sessionColumns, sessionID, streamerID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, errors.New("scheduled session not found")
+		}
+		return Session{}, err
+	}
+	return session, nil
+}
+
+func (s *Service) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.runScheduledJobs(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) runScheduledJobs(ctx context.Context) {
+	if err := s.sendDueReminders(ctx); err != nil {
+		log.Printf("stream scheduler reminders failed: %v", err)
+	}
+	if err := s.activateDueSessions(ctx); err != nil {
+		log.Printf("stream scheduler activation failed: %v", err)
+	}
+	if err := s.expireIdleSessions(ctx); err != nil {
+		log.Printf("stream scheduler expiry failed: %v", err)
+	}
+	if err := s.lottery.RevealEndedSessionSeeds(ctx); err != nil {
+		log.Printf("stream scheduler seed reveal failed: %v", err)
+	}
+	if err := s.lottery.RotateGlobalSeed(ctx, 24*time.Hour); err != nil {
+		log.Printf("stream scheduler global seed rotation failed: %v", err)
+	}
+	if err := s.expireClaims(ctx); err != nil {
+		log.Printf("stream scheduler claim expiry failed: %v", err)
+	}
+	if report, err := s.ReconcilePrizes(ctx); err != nil {
+		log.Printf("stream scheduler prize reconciliation failed: %v", err)
+	} else if report.Granted > 0 || report.Failed > 0 {
+		log.Printf("stream scheduler prize reconciliation: linked=%d granted=%d failed=%d", report.Linked, report.Granted, report.Failed)
+	}
+}
+
+func (s *Service) sendDueReminders(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+UPDATE stream_sessions ss
+SET reminder_sent_at = NOW()
+FROM users u
+WHERE u.id = ss.streamer_id
+  AND ss.status = 'scheduled'
+  AND ss.reminder_sent_at IS NULL
+  AND ss.reminder_minutes > 0
+  AND ss.scheduled_at - make_interval(mins => ss.reminder_minutes) <= NOW()
+RETURNING ss.id, ss.title, ss.invite_code, COALESCE(ss.telegram_chat_id, ''), ss.scheduled_at, COALESCE(u.telegram_id, '')
+`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type reminder struct {
+		session        Session
+		streamerChatID string
+	}
+	due := make([]reminder, 0)
+	for rows.Next() {
+		var r reminder
+		if err := rows.Scan(&r.session.ID, &r.session.Title, &r.session.InviteCode, &r.session.TelegramChatID, &r.session.ScheduledAt, &r.streamerChatID); err != nil {
+			return err
+		}
+		due = append(due, r)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if s.bot == nil {
+		return nil
+	}
+	for _, r := range due {
+		result := s.buildStartResult(r.session)
+		message := fmt.Sprintf("Reminder: %s starts at %s UTC.\nJoin giveaway pool: %s", r.session.Title, r.session.ScheduledAt.UTC().Format("2006-01-02 15:04"), result.InviteURL)
+		if r.session.TelegramChatID != "" {
+			_ = s.bot.SendMessage(ctx, r.session.TelegramChatID, message)
+		}
+		if r.streamerChatID != "" && r.streamerChatID != r.session.TelegramChatID {
+			_ = s.bot.SendMessage(ctx, r.streamerChatID, message)
+		}
+	}
+	return nil
+}
+
+func (s *Service) activateDueSessions(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+SELECT id
+FROM stream_sessions
+WHERE status = 'scheduled' AND scheduled_at <= NOW()
+ORDER BY scheduled_at
+`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.activateScheduled(ctx, id); err != nil {
+			log.Printf("stream scheduler: activate session %d failed: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) activateScheduled(ctx context.Context, sessionID int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	session, err := scanSession(tx.QueryRow(ctx, `SELECT `+sessionColumns+` FROM stream_sessions WHERE id = $1 AND status = 'scheduled' FOR UPDATE`, sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	var live bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM stream_sessions WHERE streamer_id = $1 AND status = 'active')`, session.StreamerID).Scan(&live); err != nil {
+		return err
+	}
+
+	commitment := ""
+	if live {
+		if _, err := tx.Exec(ctx, `
+UPDATE stream_sessions
+SET status = 'cancelled', ended_at = NOW(), end_reason = 'streamer_live'
+WHERE id = $1
+`, sessionID); err != nil {
+			return err
+		}
+		log.Printf("stream scheduler: session %d skipped, streamer %d is already live", sessionID, session.StreamerID)
+	} else {
+		session, err = scanSession(tx.QueryRow(ctx, `
+UPDATE stream_sessions
+SET status = 'active', started_at = NOW(), last_activity_at = NOW()
+WHERE id = $1
+RETURNING `+sessionColumns, sessionID))
+		if err != nil {
+			return err
+		}
+
+		if session.TemplateID != nil {
+			if err := s.applyRuleTemplate(ctx, tx, session.StreamerID, session.ID, *session.TemplateID); err != nil {
+				return err
+			}
+		}
+
+		commitment, err = s.lottery.CommitSessionSeed(ctx, tx, session.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if interval, ok := recurrenceInterval(session.Recurrence); ok && session.ScheduledAt != nil {
+		next := session.ScheduledAt.Add(interval)
+		for !next.After(time.Now()) {
+			next = next.Add(interval)
+		}
+		nextSession := session
+		nextSession.ScheduledAt = &next
+		created, err := insertScheduledSession(ctx, tx, nextSession)
+		if err != nil {
+			return err
+		}
+		if err := copySessionSettings(ctx, tx, session.ID, created.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if !live && s.bot != nil && session.TelegramChatID != "" {
+		result := s.buildStartResult(session)
+		message := fmt.Sprintf("%s is live. Join giveaway pool: %s\nSteam quick join: %s\nDraw commitment: %s", session.Title, result.InviteURL, result.SteamInviteURL, commitment)
+		_ = s.bot.SendMessage(ctx, session.TelegramChatID, message)
+	}
+	return nil
+}
+
+func insertScheduledSession(ctx context.Context, q queryRower, session Session) (Session, error) {
+	inviteCode, err := generateInviteCode(12)
+	if err != nil {
+		return Session{}, err
+	}
+	return scanSession(q.QueryRow(ctx, `
+INSERT INTO stream_sessions (streamer_id, title, invite_code, telegram_chat_id, status, scheduled_at, template_id, recurrence, reminder_minutes)
+VALUES ($1, $2, $3, $4, 'scheduled', $5, $6, $7, $8)
+RETURNING `+sessionColumns, session.StreamerID, session.Title, inviteCode, nullIfEmpty(session.TelegramChatID), session.ScheduledAt, session.TemplateID, session.Recurrence, session.ReminderMinutes))
+}
+
+func copySessionSettings(ctx context.Context, tx pgx.Tx, fromID, toID int64) error {
+	if _, err := tx.Exec(ctx, `
+UPDATE stream_sessions n
+SET weighting = o.weighting, weight_cap = o.weight_cap,
+    max_wins_per_hour = o.max_wins_per_hour, max_wins_per_session = o.max_wins_per_session,
+    max_win_value_cents = o.max_win_value_cents, win_limit_mode = o.win_limit_mode,
+    claim_window_minutes = o.claim_window_minutes
+FROM stream_sessions o
+WHERE n.id = $2 AND o.id = $1
+`, fromID, toID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+INSERT INTO stream_eligibility_policies (stream_session_id, require_steam, min_account_age_days, min_steam_level, min_cs2_hours, require_telegram_member, max_participants)
+SELECT $2, require_steam, min_account_age_days, min_steam_level, min_cs2_hours, require_telegram_member, max_participants
+FROM stream_eligibility_policies
+WHERE stream_session_id = $1
+ON CONFLICT (stream_session_id) DO NOTHING
+`, fromID, toID)
+	return err
+}
+
+func recurrenceInterval(recurrence string) (time.Duration, bool) {
+	switch recurrence {
+	case "daily":
+		return 24 * time.Hour, true
+	case "weekly":
+		return 7 * 24 * time.Hour, true
+	default:
+		return 0, false
+	}
+}
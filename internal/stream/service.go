@@ -6,18 +6,22 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"log"
 	"math/big"
 	"strings"
 	"time"
 
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/inventory"
 	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/steam"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	qrcode "github.com/skip2/go-qrcode"
 )
 
 type BotSender interface {
 	SendMessage(ctx context.Context, chatID, text string) error
+	IsChatMember(ctx context.Context, chatID, telegramUserID string) (bool, error)
 }
 
 type Service struct {
@@ -27,30 +31,49 @@ type Service struct {
 	bot         BotSender
 	baseURL     string
 	botUsername string
+	idleTimeout time.Duration
+	steam       steam.Client
+
+	presenceTimeout time.Duration
 }
 
 type Session struct {
-	ID             int64      `json:"id"`
-	StreamerID     int64      `json:"streamer_id"`
-	Title          string     `json:"title"`
-	InviteCode     string     `json:"invite_code"`
-	TelegramChatID string     `json:"telegram_chat_id,omitempty"`
-	Status         string     `json:"status"`
-	CreatedAt      time.Time  `json:"created_at"`
-	EndedAt        *time.Time `json:"ended_at,omitempty"`
+	ID              int64             `json:"id"`
+	StreamerID      int64             `json:"streamer_id"`
+	Title           string            `json:"title"`
+	InviteCode      string            `json:"invite_code"`
+	TelegramChatID  string            `json:"telegram_chat_id,omitempty"`
+	Status          string            `json:"status"`
+	CreatedAt       time.Time         `json:"created_at"`
+	EndedAt         *time.Time        `json:"ended_at,omitempty"`
+	StartedAt       *time.Time        `json:"started_at,omitempty"`
+	ScheduledAt     *time.Time        `json:"scheduled_at,omitempty"`
+	TemplateID      *int64            `json:"template_id,omitempty"`
+	Recurrence      string            `json:"recurrence"`
+	ReminderMinutes int               `json:"reminder_minutes"`
+	EndReason       string            `json:"end_reason,omitempty"`
+	LastActivityAt  *time.Time        `json:"last_activity_at,omitempty"`
+	Weighting       lottery.Weighting `json:"weighting"`
+	WinLimits       lottery.WinLimits `json:"win_limits"`
+	ClaimWindowMins int               `json:"claim_window_minutes"`
 }
 
+const sessionColumns = `id, streamer_id, title, invite_code, COALESCE(telegram_chat_id, ''), status, created_at, ended_at, started_at, scheduled_at, template_id, recurrence, reminder_minutes, COALESCE(end_reason, ''), last_activity_at, weighting, weight_cap, max_wins_per_hour, max_wins_per_session, max_win_value_cents, win_limit_mode, claim_window_minutes`
+
 type GiveawayRule struct {
-	ID              int64     `json:"id"`
-	StreamSessionID int64     `json:"stream_session_id"`
-	TriggerType     string    `json:"trigger_type"`
-	PrizeType       string    `json:"prize_type"`
-	PrizeName       string    `json:"prize_name"`
-	PrizeCents      int64     `json:"prize_cents"`
-	Enabled         bool      `json:"enabled"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              int64              `json:"id"`
+	StreamSessionID int64              `json:"stream_session_id"`
+	TriggerType     string             `json:"trigger_type"`
+	PrizeType       string             `json:"prize_type"`
+	PrizeName       string             `json:"prize_name"`
+	PrizeCents      int64              `json:"prize_cents"`
+	Enabled         bool               `json:"enabled"`
+	CreatedAt       time.Time          `json:"created_at"`
+	Weighting       *lottery.Weighting `json:"weighting,omitempty"`
 }
 
+const ruleColumns = `id, stream_session_id, trigger_type, prize_type, prize_name, prize_cents, enabled, created_at, weighting, weight_cap`
+
 type EventPreset struct {
 	TriggerType string `json:"trigger_type"`
 	Label       string `json:"label"`
@@ -63,13 +86,14 @@ type StartResult struct {
 	SteamInviteURL   string  `json:"steam_invite_url"`
 	TelegramDeepLink string  `json:"telegram_deeplink,omitempty"`
 	QRCodePNGBase64  string  `json:"qr_code_png_base64"`
+	SeedCommitment   string  `json:"seed_commitment,omitempty"`
 }
 
-func NewService(db *pgxpool.Pool, lottery *lottery.Service, inventory *inventory.Service, bot BotSender, baseURL, botUsername string) *Service {
-	return &Service{db: db, lottery: lottery, inventory: inventory, bot: bot, baseURL: strings.TrimRight(baseURL, "/"), botUsername: strings.TrimPrefix(botUsername, "@")}
+func NewService(db *pgxpool.Pool, lottery *lottery.Service, inventory *inventory.Service, bot BotSender, baseURL, botUsername string, idleTimeout, presenceTimeout time.Duration, steamClient steam.Client) *Service {
+	return &Service{db: db, lottery: lottery, inventory: inventory, bot: bot, baseURL: strings.TrimRight(baseURL, "/"), botUsername: strings.TrimPrefix(botUsername, "@"), idleTimeout: idleTimeout, presenceTimeout: presenceTimeout, steam: steamClient}
 }
 
-func (s *Service) StartSession(ctx context.Context, streamerID int64, title, telegramChatID string, sendToChat bool) (StartResult, error) {
+func (s *Service) StartSession(ctx context.Context, streamerID int64, title, telegramChatID string, sendToChat, replace bool, templateID *int64) (StartResult, error) {
 	title = strings.TrimSpace(title)
 	if title == "" {
 		title = "LiveDrop Session"
@@ -80,29 +104,59 @@ func (s *Service) StartSession(ctx context.Context, streamerID int64, title, tel
 		return StartResult{}, err
 	}
 
-	var session Session
-	err = s.db.QueryRow(ctx, `
-INSERT INTO stream_sessions (streamer_id, title, invite_code, telegram_chat_id)
-VALUES ($1, $2, $3, $4)
-RETURNING id, streamer_id, title, invite_code, COALESCE(telegram_chat_id, ''), status, created_at, ended_at
-`, streamerID, title, inviteCode, nullIfEmpty(telegramChatID)).Scan(
-		&session.ID,
-		&session.StreamerID,
-		&session.Title,
-		&session.InviteCode,
-		&session.TelegramChatID,
-		&session.Status,
-		&session.CreatedAt,
-		&session.EndedAt,
-	)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return StartResult{}, err
 	}
+	defer tx.Rollback(ctx)
+
+	replaced := make([]Session, 0)
+	if replace {
+		replaced, err = endActiveSessions(ctx, tx, streamerID, "replaced")
+		if err != nil {
+			return StartResult{}, err
+		}
+	} else {
+		var live bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM stream_sessions WHERE streamer_id = $1 AND status = 'active')`, streamerID).Scan(&live); err != nil {
+			return StartResult{}, err
+		}
+		if live {
+			return StartResult{}, errors.New("you already have a live session; end it first or pass replace")
+		}
+	}
+
+	session, err := scanSession(tx.QueryRow(ctx, `
+INSERT INTO stream_sessions (streamer_id, title, invite_code, telegram_chat_id, started_at, last_activity_at)
+VALUES ($1, $2, $3, $4, NOW(), NOW())
+RETURNING `+sessionColumns, streamerID, title, inviteCode, nullIfEmpty(telegramChatID)))
+	if err != nil {
+		return StartResult{}, err
+	}
+
+	if templateID != nil {
+		if err := s.applyRuleTemplate(ctx, tx, streamerID, session.ID, *templateID); err != nil {
+			return StartResult{}, err
+		}
+	}
+
+	commitment, err := s.lottery.CommitSessionSeed(ctx, tx, session.ID)
+	if err != nil {
+		return StartResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return StartResult{}, err
+	}
+	for _, old := range replaced {
+		s.finishEndedSession(ctx, old)
+	}
 
 	result := s.buildStartResult(session)
+	result.SeedCommitment = commitment
 
 	if sendToChat && s.bot != nil && session.TelegramChatID != "" {
-		message := fmt.Sprintf("%s is live. Join giveaway pool: %s\nSteam quick join: %s", session.Title, result.InviteURL, result.SteamInviteURL)
+		message := fmt.Sprintf("%s is live. Join giveaway pool: %s\nSteam quick join: %s\nDraw commitment: %s", session.Title, result.InviteURL, result.SteamInviteURL, commitment)
 		_ = s.bot.SendMessage(ctx, session.TelegramChatID, message)
 	}
 
@@ -130,48 +184,53 @@ func (s *Service) buildStartResult(session Session) StartResult {
 	}
 }
 
-func (s *Service) EndSession(ctx context.Context, streamerID, sessionID int64) (Session, error) {
-	var session Session
-	err := s.db.QueryRow(ctx, `
+func (s *Service) EndSession(ctx context.Context, actorID, sessionID int64) (Session, *SessionSummary, error) {
+	_, role, err := s.authorizeSession(ctx, actorID, sessionID, permEndSession)
+	if err != nil {
+		return Session{}, nil, err
+	}
+
+	session, err := scanSession(s.db.QueryRow(ctx, `
 UPDATE stream_sessions
-SET status = 'ended', ended_at = NOW()
-WHERE id = $1 AND streamer_id = $2 AND status = 'active'
-RETURNING id, streamer_id, title, invite_code, COALESCE(telegram_chat_id, ''), status, created_at, ended_at
-`, sessionID, streamerID).Scan(
-		&session.ID,
-		&session.StreamerID,
-		&session.Title,
-		&session.InviteCode,
-		&session.TelegramChatID,
-		&session.Status,
-		&session.CreatedAt,
-		&session.EndedAt,
-	)
+SET status = 'ended', ended_at = NOW(), end_reason = 'manual'
+WHERE id = $1 AND status = 'active'
+RETURNING `+sessionColumns, sessionID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Session{}, nil, errors.New("stream session is not active")
+	}
 	if err != nil {
-		return Session{}, err
+		return Session{}, nil, err
 	}
-	return session, nil
+	s.auditSession(ctx, session, actorID, role, "end_session", nil)
+	return session, s.finishEndedSession(ctx, session), nil
+}
+
+func (s *Service) finishEndedSession(ctx context.Context, session Session) *SessionSummary {
+	_ = s.lottery.RevealSessionSeed(ctx, session.ID)
+	summary, err := s.buildSummary(ctx, session)
+	if err != nil {
+		return nil
+	}
+	s.postSummary(ctx, summary)
+	return &summary
+}
+
+func (s *Service) getSession(ctx context.Context, sessionID int64) (Session, error) {
+	return scanSession(s.db.QueryRow(ctx, `
+SELECT `+sessionColumns+`
+FROM stream_sessions
+WHERE id = $1
+`, sessionID))
 }
 
 func (s *Service) GetActiveByStreamer(ctx context.Context, streamerID int64) (Session, error) {
-	var session Session
-	err := s.db.QueryRow(ctx, `
-SELECT id, streamer_id, title, invite_code, COALESCE(telegram_chat_id, ''), status, created_at, ended_at
+	return scanSession(s.db.QueryRow(ctx, `
+SELECT `+sessionColumns+`
 FROM stream_sessions
 WHERE streamer_id = $1 AND status = 'active'
 ORDER BY created_at DESC
 LIMIT 1
-`, streamerID).Scan(
-		&session.ID,
-		&session.StreamerID,
-		&session.Title,
-		&session.InviteCode,
-		&session.TelegramChatID,
-		&session.Status,
-		&session.CreatedAt,
-		&session.EndedAt,
-	)
-	return session, err
+`, streamerID))
 }
 
 func (s *Service) JoinByInvite(ctx context.Context, inviteCode string, userID int64) (Session, error) {
@@ -180,35 +239,95 @@ func (s *Service) JoinByInvite(ctx context.Context, inviteCode string, userID in
 		return Session{}, errors.New("invite code is required")
 	}
 
-	var session Session
-	err := s.db.QueryRow(ctx, `
-SELECT id, streamer_id, title, invite_code, COALESCE(telegram_chat_id, ''), status, created_at, ended_at
-FROM stream_sessions
-WHERE invite_code = $1 AND status = 'active'
-`, inviteCode).Scan(
-		&session.ID,
-		&session.StreamerID,
-		&session.Title,
-		&session.InviteCode,
-		&session.TelegramChatID,
-		&session.Status,
-		&session.CreatedAt,
-		&session.EndedAt,
-	)
+	session, link, err := resolveInvite(ctx, s.db, inviteCode)
 	if err != nil {
 		return Session{}, err
 	}
+	switch session.Status {
+	case "active":
+	case "scheduled":
+		return Session{}, errors.New("stream has not started yet")
+	default:
+		return Session{}, errors.New("stream session is not active")
+	}
 
-	_, err = s.db.Exec(ctx, `
-INSERT INTO stream_participants (stream_session_id, user_id)
-VALUES ($1, $2)
-ON CONFLICT (stream_session_id, user_id) DO NOTHING
-`, session.ID, userID)
+	var joined bool
+	if err := s.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM stream_participants WHERE stream_session_id = $1 AND user_id = $2)`, session.ID, userID).Scan(&joined); err != nil {
+		return Session{}, err
+	}
+	if joined {
+		return session, nil
+	}
+	banned, err := isBanned(ctx, s.db, session, userID)
+	if err != nil {
+		return Session{}, err
+	}
+	if banned {
+		return Session{}, errors.New("you are banned from this stream")
+	}
+	policy, err := s.checkEligibility(ctx, session, userID)
+	if err != nil {
+		return Session{}, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return Session{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM stream_sessions WHERE id = $1 FOR UPDATE`, session.ID); err != nil {
+		return Session{}, err
+	}
+
+	banned, err = isBanned(ctx, tx, session, userID)
 	if err != nil {
 		return Session{}, err
 	}
+	if banned {
+		return Session{}, errors.New("you are banned from this stream")
+	}
 
-	_ = s.lottery.RecordActivity(ctx, userID, 2)
+	var alreadyJoined bool
+	if err := tx.QueryRow(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM stream_participants
+	WHERE stream_session_id = $1 AND user_id = $2
+)
+`, session.ID, userID).Scan(&alreadyJoined); err != nil {
+		return Session{}, err
+	}
+	if alreadyJoined {
+		return session, nil
+	}
+
+	var linkID *int64
+	if link != nil {
+		if err := claimInviteLink(ctx, tx, link.ID); err != nil {
+			return Session{}, err
+		}
+		linkID = &link.ID
+	}
+
+	if err := checkCapacity(ctx, tx, session.ID, policy); err != nil {
+		return Session{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+INSERT INTO stream_participants (stream_session_id, user_id, invite_link_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (stream_session_id, user_id) DO NOTHING
+`, session.ID, userID, linkID); err != nil {
+		return Session{}, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE stream_sessions SET last_activity_at = NOW() WHERE id = $1`, session.ID); err != nil {
+		return Session{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Session{}, err
+	}
+
+	_ = s.lottery.RecordActivity(ctx, userID, &session.ID, lottery.ActivityJoin)
 	return session, nil
 }
 
@@ -240,7 +359,7 @@ ORDER BY joined_at DESC
 	return result, rows.Err()
 }
 
-func (s *Service) AddGiveawayRule(ctx context.Context, streamerID, sessionID int64, triggerType, prizeType, prizeName string, prizeCents int64) (GiveawayRule, error) {
+func (s *Service) AddGiveawayRule(ctx context.Context, actorID, sessionID int64, triggerType, prizeType, prizeName string, prizeCents int64, weighting *lottery.Weighting) (GiveawayRule, error) {
 	if triggerType == "" || prizeName == "" {
 		return GiveawayRule{}, errors.New("trigger_type and prize_name are required")
 	}
@@ -252,35 +371,34 @@ func (s *Service) AddGiveawayRule(ctx context.Context, streamerID, sessionID int
 		return GiveawayRule{}, errors.New("prize_cents cannot be negative")
 	}
 
-	var owner int64
-	if err := s.db.QueryRow(ctx, `SELECT streamer_id FROM stream_sessions WHERE id = $1`, sessionID).Scan(&owner); err != nil {
+	strategy, weightCap, err := ruleWeighting(weighting)
+	if err != nil {
 		return GiveawayRule{}, err
 	}
-	if owner != streamerID {
-		return GiveawayRule{}, errors.New("not your stream session")
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageRules)
+	if err != nil {
+		return GiveawayRule{}, err
 	}
 
-	var rule GiveawayRule
-	err := s.db.QueryRow(ctx, `
-INSERT INTO giveaway_rules (stream_session_id, trigger_type, prize_type, prize_name, prize_cents)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, stream_session_id, trigger_type, prize_type, prize_name, prize_cents, enabled, created_at
-`, sessionID, strings.ToLower(strings.TrimSpace(triggerType)), prizeType, strings.TrimSpace(prizeName), prizeCents).Scan(
-		&rule.ID,
-		&rule.StreamSessionID,
-		&rule.TriggerType,
-		&rule.PrizeType,
-		&rule.PrizeName,
-		&rule.PrizeCents,
-		&rule.Enabled,
-		&rule.CreatedAt,
-	)
-	return rule, err
+	rule, err := scanRule(s.db.QueryRow(ctx, `
+INSERT INTO giveaway_rules (stream_session_id, trigger_type, prize_type, prize_name, prize_cents, weighting, weight_cap)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING `+ruleColumns, sessionID, strings.ToLower(strings.TrimSpace(triggerType)), prizeType, strings.TrimSpace(prizeName), prizeCents, strategy, weightCap))
+	if err != nil {
+		return GiveawayRule{}, err
+	}
+	s.auditSession(ctx, session, actorID, role, "add_giveaway_rule", map[string]interface{}{"rule_id": rule.ID, "trigger_type": rule.TriggerType, "prize_name": rule.PrizeName, "prize_cents": rule.PrizeCents})
+	return rule, nil
 }
 
-func (s *Service) ListGiveawayRules(ctx context.Context, sessionID int64) ([]GiveawayRule, error) {
+func (s *Service) ListGiveawayRules(ctx context.Context, actorID, sessionID int64) ([]GiveawayRule, error) {
+	if _, _, err := s.authorizeSession(ctx, actorID, sessionID, permViewSession); err != nil {
+		return nil, err
+	}
+
 	rows, err := s.db.Query(ctx, `
-SELECT id, stream_session_id, trigger_type, prize_type, prize_name, prize_cents, enabled, created_at
+SELECT `+ruleColumns+`
 FROM giveaway_rules
 WHERE stream_session_id = $1
 ORDER BY created_at DESC
@@ -292,8 +410,8 @@ ORDER BY created_at DESC
 
 	result := make([]GiveawayRule, 0)
 	for rows.Next() {
-		var rule GiveawayRule
-		if err := rows.Scan(&rule.ID, &rule.StreamSessionID, &rule.TriggerType, &rule.PrizeType, &rule.PrizeName, &rule.PrizeCents, &rule.Enabled, &rule.CreatedAt); err != nil {
+		rule, err := scanRule(rows)
+		if err != nil {
 			return nil, err
 		}
 		result = append(result, rule)
@@ -312,7 +430,7 @@ func (s *Service) ListEventPresets() []EventPreset {
 	}
 }
 
-func (s *Service) UpdateGiveawayRule(ctx context.Context, streamerID, sessionID, ruleID int64, triggerType, prizeType, prizeName string, prizeCents int64, enabled bool) (GiveawayRule, error) {
+func (s *Service) UpdateGiveawayRule(ctx context.Context, actorID, sessionID, ruleID int64, triggerType, prizeType, prizeName string, prizeCents int64, enabled bool, weighting *lottery.Weighting) (GiveawayRule, error) {
 	if triggerType == "" || prizeName == "" {
 		return GiveawayRule{}, errors.New("trigger_type and prize_name are required")
 	}
@@ -321,41 +439,33 @@ func (s *Service) UpdateGiveawayRule(ctx context.Context, streamerID, sessionID,
 		return GiveawayRule{}, errors.New("prize_type must be skin or case")
 	}
 
-	var owner int64
-	if err := s.db.QueryRow(ctx, `SELECT streamer_id FROM stream_sessions WHERE id = $1`, sessionID).Scan(&owner); err != nil {
+	strategy, weightCap, err := ruleWeighting(weighting)
+	if err != nil {
 		return GiveawayRule{}, err
 	}
-	if owner != streamerID {
-		return GiveawayRule{}, errors.New("not your stream session")
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageRules)
+	if err != nil {
+		return GiveawayRule{}, err
 	}
 
-	var rule GiveawayRule
-	err := s.db.QueryRow(ctx, `
+	rule, err := scanRule(s.db.QueryRow(ctx, `
 UPDATE giveaway_rules
-SET trigger_type = $1, prize_type = $2, prize_name = $3, prize_cents = $4, enabled = $5
-WHERE id = $6 AND stream_session_id = $7
-RETURNING id, stream_session_id, trigger_type, prize_type, prize_name, prize_cents, enabled, created_at
-`, strings.ToLower(strings.TrimSpace(triggerType)), prizeType, strings.TrimSpace(prizeName), prizeCents, enabled, ruleID, sessionID).Scan(
-		&rule.ID,
-		&rule.StreamSessionID,
-		&rule.TriggerType,
-		&rule.PrizeType,
-		&rule.PrizeName,
-		&rule.PrizeCents,
-		&rule.Enabled,
-		&rule.CreatedAt,
-	)
-	return rule, err
+SET trigger_type = $1, prize_type = $2, prize_name = $3, prize_cents = $4, enabled = $5, weighting = $6, weight_cap = $7
+WHERE id = $8 AND stream_session_id = $9
+RETURNING `+ruleColumns, strings.ToLower(strings.TrimSpace(triggerType)), prizeType, strings.TrimSpace(prizeName), prizeCents, enabled, strategy, weightCap, ruleID, sessionID))
+	if err != nil {
+		return GiveawayRule{}, err
+	}
+	s.auditSession(ctx, session, actorID, role, "update_giveaway_rule", map[string]interface{}{"rule_id": rule.ID, "trigger_type": rule.TriggerType, "prize_name": rule.PrizeName, "prize_cents": rule.PrizeCents, "enabled": rule.Enabled})
+	return rule, nil
 }
 
-func (s *Service) DeleteGiveawayRule(ctx context.Context, streamerID, sessionID, ruleID int64) error {
-	var owner int64
-	if err := s.db.QueryRow(ctx, `SELECT streamer_id FROM stream_sessions WHERE id = $1`, sessionID).Scan(&owner); err != nil {
+func (s *Service) DeleteGiveawayRule(ctx context.Context, actorID, sessionID, ruleID int64) error {
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageRules)
+	if err != nil {
 		return err
 	}
-	if owner != streamerID {
-		return errors.New("not your stream session")
-	}
 
 	result, err := s.db.Exec(ctx, `DELETE FROM giveaway_rules WHERE id = $1 AND stream_session_id = $2`, ruleID, sessionID)
 	if err != nil {
@@ -364,6 +474,7 @@ func (s *Service) DeleteGiveawayRule(ctx context.Context, streamerID, sessionID,
 	if result.RowsAffected() == 0 {
 		return errors.New("rule not found")
 	}
+	s.auditSession(ctx, session, actorID, role, "delete_giveaway_rule", map[string]interface{}{"rule_id": ruleID})
 	return nil
 }
 
@@ -374,7 +485,7 @@ func (s *Service) HandleGameEvent(ctx context.Context, streamerID int64, eventTy
 	}
 
 	rows, err := s.db.Query(ctx, `
-SELECT id, stream_session_id, trigger_type, prize_type, prize_name, prize_cents, enabled, created_at
+SELECT `+ruleColumns+`
 FROM giveaway_rules
 WHERE stream_session_id = $1 AND enabled = TRUE AND trigger_type = $2
 `, session.ID, strings.ToLower(strings.TrimSpace(eventType)))
@@ -385,8 +496,8 @@ WHERE stream_session_id = $1 AND enabled = TRUE AND trigger_type = $2
 
 	rules := make([]GiveawayRule, 0)
 	for rows.Next() {
-		var rule GiveawayRule
-		if err := rows.Scan(&rule.ID, &rule.StreamSessionID, &rule.TriggerType, &rule.PrizeType, &rule.PrizeName, &rule.PrizeCents, &rule.Enabled, &rule.CreatedAt); err != nil {
+		rule, err := scanRule(rows)
+		if err != nil {
 			return nil, err
 		}
 		rules = append(rules, rule)
@@ -395,7 +506,7 @@ WHERE stream_session_id = $1 AND enabled = TRUE AND trigger_type = $2
 		return nil, nil
 	}
 
-	participants, err := s.ListParticipants(ctx, session.ID)
+	participants, err := s.ListPresentParticipants(ctx, session.ID)
 	if err != nil || len(participants) == 0 {
 		return nil, err
 	}
@@ -403,27 +514,80 @@ WHERE stream_session_id = $1 AND enabled = TRUE AND trigger_type = $2
 	streamID := session.ID
 	triggered := make([]lottery.Round, 0)
 	for _, rule := range rules {
+		deliver := s.prizeDeliverer(rule.PrizeType, rule.PrizeName, rule.PrizeCents, map[string]interface{}{
+			"stream_session_id": session.ID,
+			"rule_id":           rule.ID,
+			"trigger_type":      rule.TriggerType,
+		})
 		round, err := s.lottery.TriggerForUsers(ctx, rule.TriggerType, triggerEventID, &streamID, rule.PrizeCents, participants, map[string]interface{}{
 			"prize_type": rule.PrizeType,
 			"prize_name": rule.PrizeName,
 			"rule_id":    rule.ID,
-		})
-		if err == nil && round != nil {
+		}, session.drawOptions(rule.drawWeighting(session)), deliver)
+		if err != nil {
+			log.Printf("stream giveaway payout failed: session=%d rule=%d err=%v", session.ID, rule.ID, err)
+			continue
+		}
+		if round != nil {
+			s.notifyClaim(ctx, session, *round, rule.PrizeName)
 			triggered = append(triggered, *round)
-			if s.inventory != nil && round.WinnerUserID != nil {
-				_, _ = s.inventory.GrantItem(ctx, *round.WinnerUserID, rule.PrizeType, rule.PrizeName, "restricted", "stream_giveaway", map[string]interface{}{
-					"stream_session_id": session.ID,
-					"rule_id":           rule.ID,
-					"trigger_type":      rule.TriggerType,
-					"price_cents":       rule.PrizeCents,
-				})
-			}
 		}
 	}
 
 	return triggered, nil
 }
 
+func scanSession(row pgx.Row) (Session, error) {
+	var session Session
+	err := row.Scan(
+		&session.ID,
+		&session.StreamerID,
+		&session.Title,
+		&session.InviteCode,
+		&session.TelegramChatID,
+		&session.Status,
+		&session.CreatedAt,
+		&session.EndedAt,
+		&session.StartedAt,
+		&session.ScheduledAt,
+		&session.TemplateID,
+		&session.Recurrence,
+		&session.ReminderMinutes,
+		&session.EndReason,
+		&session.LastActivityAt,
+		&session.Weighting.Strategy,
+		&session.Weighting.Cap,
+		&session.WinLimits.MaxWinsPerHour,
+		&session.WinLimits.MaxWinsPerSession,
+		&session.WinLimits.MaxWinValueCents,
+		&session.WinLimits.Mode,
+		&session.ClaimWindowMins,
+	)
+	return session, err
+}
+
+func scanRule(row pgx.Row) (GiveawayRule, error) {
+	var rule GiveawayRule
+	var strategy *string
+	var weightCap int64
+	err := row.Scan(
+		&rule.ID,
+		&rule.StreamSessionID,
+		&rule.TriggerType,
+		&rule.PrizeType,
+		&rule.PrizeName,
+		&rule.PrizeCents,
+		&rule.Enabled,
+		&rule.CreatedAt,
+		&strategy,
+		&weightCap,
+	)
+	if strategy != nil {
+		rule.Weighting = &lottery.Weighting{Strategy: *strategy, Cap: weightCap}
+	}
+	return rule, err
+}
+
 func generateInviteCode(length int) (string, error) {
 	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
 	result := make([]byte, length)
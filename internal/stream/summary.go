@@ -0,0 +1,366 @@
+package stream
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SessionSummary struct {
+	Session           Session              `json:"session"`
+	DurationSeconds   int64                `json:"duration_seconds"`
+	ParticipantCount  int                  `json:"participant_count"`
+	Participants      []SummaryParticipant `json:"participants"`
+	JoinTimeline      []JoinTimelinePoint  `json:"join_timeline"`
+	EventsByType      map[string]int64     `json:"events_by_type"`
+	Rounds            []SummaryRound       `json:"rounds"`
+	TotalPaidOutCents int64                `json:"total_paid_out_cents"`
+	Campaigns         []SummaryCampaign    `json:"campaigns"`
+}
+
+type SummaryParticipant struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type JoinTimelinePoint struct {
+	Minute time.Time `json:"minute"`
+	Joins  int64     `json:"joins"`
+	Total  int64     `json:"total"`
+}
+
+type SummaryRound struct {
+	RoundID        int64     `json:"round_id"`
+	TriggerType    string    `json:"trigger_type"`
+	WinnerUserID   *int64    `json:"winner_user_id,omitempty"`
+	WinnerUsername string    `json:"winner_username,omitempty"`
+	PrizeType      string    `json:"prize_type,omitempty"`
+	PrizeName      string    `json:"prize_name,omitempty"`
+	PrizeCents     int64     `json:"prize_cents"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type SummaryCampaign struct {
+	CaseID            int64  `json:"case_id"`
+	Title             string `json:"title"`
+	RewardItemType    string `json:"reward_item_type"`
+	RewardItemName    string `json:"reward_item_name"`
+	TargetAmountCents int64  `json:"target_amount_cents"`
+	RaisedCents       int64  `json:"raised_cents"`
+	Contributors      int64  `json:"contributors"`
+	Status            string `json:"status"`
+	WinnerUserID      *int64 `json:"winner_user_id,omitempty"`
+	WinnerUsername    string `json:"winner_username,omitempty"`
+}
+
+func (s *Service) GetSessionSummary(ctx context.Context, actorID, sessionID int64) (SessionSummary, error) {
+	session, _, err := s.authorizeSession(ctx, actorID, sessionID, permModerate)
+	if err != nil {
+		return SessionSummary{}, err
+	}
+	return s.buildSummary(ctx, session)
+}
+
+func (s *Service) buildSummary(ctx context.Context, session Session) (SessionSummary, error) {
+	summary := SessionSummary{
+		Session:      session,
+		Participants: make([]SummaryParticipant, 0),
+		JoinTimeline: make([]JoinTimelinePoint, 0),
+		EventsByType: map[string]int64{},
+		Rounds:       make([]SummaryRound, 0),
+		Campaigns:    make([]SummaryCampaign, 0),
+	}
+
+	startedAt := session.CreatedAt
+	if session.StartedAt != nil {
+		startedAt = *session.StartedAt
+	}
+	endedAt := time.Now()
+	if session.EndedAt != nil {
+		endedAt = *session.EndedAt
+	}
+	summary.DurationSeconds = int64(endedAt.Sub(startedAt).Seconds())
+
+	rows, err := s.db.Query(ctx, `
+SELECT sp.user_id, COALESCE(u.username, ''), sp.joined_at
+FROM stream_participants sp
+JOIN users u ON u.id = sp.user_id
+WHERE sp.stream_session_id = $1
+ORDER BY sp.joined_at
+`, session.ID)
+	if err != nil {
+		return SessionSummary{}, err
+	}
+	for rows.Next() {
+		var p SummaryParticipant
+		if err := rows.Scan(&p.UserID, &p.Username, &p.JoinedAt); err != nil {
+			rows.Close()
+			return SessionSummary{}, err
+		}
+		summary.Participants = append(summary.Participants, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return SessionSummary{}, err
+	}
+	summary.ParticipantCount = len(summary.Participants)
+
+	running := int64(0)
+	for _, p := range summary.Participants {
+		minute := p.JoinedAt.UTC().Truncate(time.Minute)
+		running++
+		last := len(summary.JoinTimeline) - 1
+		if last >= 0 && summary.JoinTimeline[last].Minute.Equal(minute) {
+			summary.JoinTimeline[last].Joins++
+			summary.JoinTimeline[last].Total = running
+			continue
+		}
+		summary.JoinTimeline = append(summary.JoinTimeline, JoinTimelinePoint{Minute: minute, Joins: 1, Total: running})
+	}
+
+	rows, err = s.db.Query(ctx, `
+SELECT event_type, COUNT(*)
+FROM events
+WHERE user_id = $1 AND created_at >= $2 AND created_at <= $3
+GROUP BY event_type
+`, session.StreamerID, startedAt, endedAt)
+	if err != nil {
+		return SessionSummary{}, err
+	}
+	for rows.Next() {
+		var eventType string
+		var count int64
+		if err := rows.Scan(&eventType, &count); err != nil {
+			rows.Close()
+			return SessionSummary{}, err
+		}
+		summary.EventsByType[eventType] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return SessionSummary{}, err
+	}
+
+	rows, err = s.db.Query(ctx, `
+SELECT lr.id, lr.trigger_type, lr.winner_user_id, COALESCE(u.username, ''), COALESCE(lr.details->>'prize_type', ''), COALESCE(lr.details->>'prize_name', ''), lr.prize_cents, lr.created_at,
+       lr.case_id IS NULL AND lr.trigger_type <> 'raffle' AND lr.winner_user_id IS NOT NULL
+           AND COALESCE(lr.claim_status, 'claimed') = 'claimed' AND lr.prize_delivered_at IS NOT NULL
+FROM lottery_rounds lr
+LEFT JOIN users u ON u.id = lr.winner_user_id
+WHERE lr.stream_session_id = $1
+ORDER BY lr.created_at
+`, session.ID)
+	if err != nil {
+		return SessionSummary{}, err
+	}
+	for rows.Next() {
+		var r SummaryRound
+		var paidOut bool
+		if err := rows.Scan(&r.RoundID, &r.TriggerType, &r.WinnerUserID, &r.WinnerUsername, &r.PrizeType, &r.PrizeName, &r.PrizeCents, &r.CreatedAt, &paidOut); err != nil {
+			rows.Close()
+			return SessionSummary{}, err
+		}
+		summary.Rounds = append(summary.Rounds, r)
+		if paidOut {
+			summary.TotalPaidOutCents += r.PrizeCents
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return SessionSummary{}, err
+	}
+
+	rows, err = s.db.Query(ctx, `
+SELECT c.id, c.title, c.reward_item_type, c.reward_item_name, c.target_amount_cents,
+       COALESCE(SUM(cc.amount_cents), 0), COUNT(DISTINCT cc.user_id), c.status,
+       winner.winner_user_id, COALESCE(winner.username, '')
+FROM cases c
+LEFT JOIN case_contributions cc ON cc.case_id = c.id
+LEFT JOIN LATERAL (
+    SELECT lr.winner_user_id, u.username
+    FROM lottery_rounds lr
+    LEFT JOIN users u ON u.id = lr.winner_user_id
+    WHERE lr.case_id = c.id AND lr.winner_user_id IS NOT NULL
+    ORDER BY lr.created_at DESC
+    LIMIT 1
+) winner ON TRUE
+WHERE c.stream_session_id = $1
+GROUP BY c.id, winner.winner_user_id, winner.username
+ORDER BY c.created_at
+`, session.ID)
+	if err != nil {
+		return SessionSummary{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c SummaryCampaign
+		if err := rows.Scan(&c.CaseID, &c.Title, &c.RewardItemType, &c.RewardItemName, &c.TargetAmountCents, &c.RaisedCents, &c.Contributors, &c.Status, &c.WinnerUserID, &c.WinnerUsername); err != nil {
+			return SessionSummary{}, err
+		}
+		summary.Campaigns = append(summary.Campaigns, c)
+	}
+	return summary, rows.Err()
+}
+
+func (s *Service) postSummary(ctx context.Context, summary SessionSummary) {
+	if s.bot == nil || summary.Session.TelegramChatID == "" {
+		return
+	}
+	_ = s.bot.SendMessage(ctx, summary.Session.TelegramChatID, formatSummaryMessage(summary))
+}
+
+func formatSummaryMessage(summary SessionSummary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s has ended.\n", summary.Session.Title)
+	fmt.Fprintf(&b, "Duration: %s\n", (time.Duration(summary.DurationSeconds) * time.Second).String())
+	fmt.Fprintf(&b, "Participants: %d\n", summary.ParticipantCount)
+
+	if len(summary.EventsByType) > 0 {
+		types := make([]string, 0, len(summary.EventsByType))
+		for t := range summary.EventsByType {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		parts := make([]string, 0, len(types))
+		for _, t := range types {
+			parts = append(parts, fmt.Sprintf("%s x%d", t, summary.EventsByType[t]))
+		}
+		fmt.Fprintf(&b, "Events: %s\n", strings.Join(parts, ", "))
+	}
+
+	fmt.Fprintf(&b, "\nGiveaways: %d (paid out %s)\n", len(summary.Rounds), formatCents(summary.TotalPaidOutCents))
+	for _, r := range summary.Rounds {
+		winner := "no winner"
+		if r.WinnerUsername != "" {
+			winner = r.WinnerUsername
+		}
+		prize := r.PrizeName
+		if prize == "" {
+			prize = formatCents(r.PrizeCents)
+		}
+		fmt.Fprintf(&b, "- %s: %s won %s\n", r.TriggerType, winner, prize)
+	}
+
+	if len(summary.Campaigns) > 0 {
+		b.WriteString("\nCrowdfunding:\n")
+		for _, c := range summary.Campaigns {
+			line := fmt.Sprintf("- %s: %s / %s (%s)", c.Title, formatCents(c.RaisedCents), formatCents(c.TargetAmountCents), c.Status)
+			if c.WinnerUsername != "" {
+				line += ", winner " + c.WinnerUsername
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func WriteSummaryCSVBundle(w io.Writer, summary SessionSummary) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		rows [][]string
+	}{
+		{name: "participants.csv", rows: participantsCSV(summary)},
+		{name: "join_timeline.csv", rows: timelineCSV(summary)},
+		{name: "events.csv", rows: eventsCSV(summary)},
+		{name: "rounds.csv", rows: roundsCSV(summary)},
+		{name: "campaigns.csv", rows: campaignsCSV(summary)},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		if err := cw.WriteAll(f.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func participantsCSV(summary SessionSummary) [][]string {
+	rows := [][]string{{"user_id", "username", "joined_at"}}
+	for _, p := range summary.Participants {
+		rows = append(rows, []string{strconv.FormatInt(p.UserID, 10), p.Username, p.JoinedAt.UTC().Format(time.RFC3339)})
+	}
+	return rows
+}
+
+func timelineCSV(summary SessionSummary) [][]string {
+	rows := [][]string{{"minute", "joins", "total"}}
+	for _, p := range summary.JoinTimeline {
+		rows = append(rows, []string{p.Minute.UTC().Format(time.RFC3339), strconv.FormatInt(p.Joins, 10), strconv.FormatInt(p.Total, 10)})
+	}
+	return rows
+}
+
+func eventsCSV(summary SessionSummary) [][]string {
+	types := make([]string, 0, len(summary.EventsByType))
+	for t := range summary.EventsByType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	rows := [][]string{{"event_type", "count"}}
+	for _, t := range types {
+		rows = append(rows, []string{t, strconv.FormatInt(summary.EventsByType[t], 10)})
+	}
+	return rows
+}
+
+func roundsCSV(summary SessionSummary) [][]string {
+	rows := [][]string{{"round_id", "trigger_type", "winner_user_id", "winner_username", "prize_type", "prize_name", "prize_cents", "created_at"}}
+	for _, r := range summary.Rounds {
+		winnerID := ""
+		if r.WinnerUserID != nil {
+			winnerID = strconv.FormatInt(*r.WinnerUserID, 10)
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(r.RoundID, 10),
+			r.TriggerType,
+			winnerID,
+			r.WinnerUsername,
+			r.PrizeType,
+			r.PrizeName,
+			strconv.FormatInt(r.PrizeCents, 10),
+			r.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func campaignsCSV(summary SessionSummary) [][]string {
+	rows := [][]string{{"case_id", "title", "reward_item_type", "reward_item_name", "target_amount_cents", "raised_cents", "contributors", "status", "winner_user_id", "winner_username"}}
+	for _, c := range summary.Campaigns {
+		winnerID := ""
+		if c.WinnerUserID != nil {
+			winnerID = strconv.FormatInt(*c.WinnerUserID, 10)
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(c.CaseID, 10),
+			c.Title,
+			c.RewardItemType,
+			c.RewardItemName,
+			strconv.FormatInt(c.TargetAmountCents, 10),
+			strconv.FormatInt(c.RaisedCents, 10),
+			strconv.FormatInt(c.Contributors, 10),
+			c.Status,
+			winnerID,
+			c.WinnerUsername,
+		})
+	}
+	return rows
+}
+
+func formatCents(cents int64) string {
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
+}
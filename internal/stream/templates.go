@@ -0,0 +1,322 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+	"github.com/jackc/pgx/v5"
+)
+
+type RuleTemplate struct {
+	ID         int64          `json:"id"`
+	StreamerID int64          `json:"streamer_id"`
+	Name       string         `json:"name"`
+	Rules      []TemplateRule `json:"rules"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+type TemplateRule struct {
+	TriggerType string             `json:"trigger_type"`
+	PrizeType   string             `json:"prize_type"`
+	PrizeName   string             `json:"prize_name"`
+	PrizeCents  int64              `json:"prize_cents"`
+	Enabled     bool               `json:"enabled"`
+	Weighting   *lottery.Weighting `json:"weighting,omitempty"`
+}
+
+func (r *TemplateRule) UnmarshalJSON(data []byte) error {
+	type plain TemplateRule
+	rule := plain{Enabled: true}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	*r = TemplateRule(rule)
+	return nil
+}
+
+func (s *Service) CreateRuleTemplate(ctx context.Context, streamerID int64, name string, rules []TemplateRule) (RuleTemplate, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return RuleTemplate{}, errors.New("template name is required")
+	}
+	rules, err := normalizeTemplateRules(rules)
+	if err != nil {
+		return RuleTemplate{}, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return RuleTemplate{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var tpl RuleTemplate
+	err = tx.QueryRow(ctx, `
+INSERT INTO giveaway_rule_templates (streamer_id, name)
+VALUES ($1, $2)
+RETURNING id, streamer_id, name, created_at, updated_at
+`, streamerID, name).Scan(&tpl.ID, &tpl.StreamerID, &tpl.Name, &tpl.CreatedAt, &tpl.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return RuleTemplate{}, errors.New("template with this name already exists")
+		}
+		return RuleTemplate{}, err
+	}
+
+	if err := insertTemplateRules(ctx, tx, tpl.ID, rules); err != nil {
+		return RuleTemplate{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return RuleTemplate{}, err
+	}
+
+	tpl.Rules = rules
+	return tpl, nil
+}
+
+func (s *Service) ListRuleTemplates(ctx context.Context, streamerID int64) ([]RuleTemplate, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT id, streamer_id, name, created_at, updated_at
+FROM giveaway_rule_templates
+WHERE streamer_id = $1
+ORDER BY name
+`, streamerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]RuleTemplate, 0)
+	for rows.Next() {
+		var tpl RuleTemplate
+		if err := rows.Scan(&tpl.ID, &tpl.StreamerID, &tpl.Name, &tpl.CreatedAt, &tpl.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, tpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range templates {
+		rules, err := s.listTemplateRules(ctx, templates[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		templates[i].Rules = rules
+	}
+	return templates, nil
+}
+
+func (s *Service) GetRuleTemplate(ctx context.Context, streamerID, templateID int64) (RuleTemplate, error) {
+	var tpl RuleTemplate
+	err := s.db.QueryRow(ctx, `
+SELECT id, streamer_id, name, created_at, updated_at
+FROM giveaway_rule_templates
+WHERE id = $1 AND streamer_id = $2
+`, templateID, streamerID).Scan(&tpl.ID, &tpl.StreamerID, &tpl.Name, &tpl.CreatedAt, &tpl.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RuleTemplate{}, errors.New("rule template not found")
+		}
+		return RuleTemplate{}, err
+	}
+
+	rules, err := s.listTemplateRules(ctx, tpl.ID)
+	if err != nil {
+		return RuleTemplate{}, err
+	}
+	tpl.Rules = rules
+	return tpl, nil
+}
+
+func (s *Service) UpdateRuleTemplate(ctx context.Context, streamerID, templateID int64, name string, rules []TemplateRule) (RuleTemplate, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return RuleTemplate{}, errors.New("template name is required")
+	}
+	rules, err := normalizeTemplateRules(rules)
+	if err != nil {
+		return RuleTemplate{}, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return RuleTemplate{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var tpl RuleTemplate
+	err = tx.QueryRow(ctx, `
+UPDATE giveaway_rule_templates
+SET name = $1, updated_at = NOW()
+WHERE id = $2 AND streamer_id = $3
+RETURNING id, streamer_id, name, created_at, updated_at
+`, name, templateID, streamerID).Scan(&tpl.ID, &tpl.StreamerID, &tpl.Name, &tpl.CreatedAt, &tpl.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RuleTemplate{}, errors.New("rule template not found")
+		}
+		if strings.Contains(err.Error(), "duplicate key") {
+			return RuleTemplate{}, errors.New("template with this name already exists")
+		}
+		return RuleTemplate{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM giveaway_rule_template_items WHERE template_id = $1`, tpl.ID); err != nil {
+		return RuleTemplate{}, err
+	}
+	if err := insertTemplateRules(ctx, tx, tpl.ID, rules); err != nil {
+		return RuleTemplate{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return RuleTemplate{}, err
+	}
+
+	tpl.Rules = rules
+	return tpl, nil
+}
+
+func (s *Service) DeleteRuleTemplate(ctx context.Context, streamerID, templateID int64) error {
+	result, err := s.db.Exec(ctx, `DELETE FROM giveaway_rule_templates WHERE id = $1 AND streamer_id = $2`, templateID, streamerID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("rule template not found")
+	}
+	return nil
+}
+
+func (s *Service) SaveSessionAsTemplate(ctx context.Context, streamerID, sessionID int64, name string) (RuleTemplate, error) {
+	var owner int64
+	if err := s.db.QueryRow(ctx, `SELECT streamer_id FROM stream_sessions WHERE id = $1`, sessionID).Scan(&owner); err != nil {
+		return RuleTemplate{}, err
+	}
+	if owner != streamerID {
+		return RuleTemplate{}, errors.New("not your stream session")
+	}
+
+	current, err := s.ListGiveawayRules(ctx, streamerID, sessionID)
+	if err != nil {
+		return RuleTemplate{}, err
+	}
+	if len(current) == 0 {
+		return RuleTemplate{}, errors.New("session has no giveaway rules to save")
+	}
+
+	rules := make([]TemplateRule, 0, len(current))
+	for i := len(current) - 1; i >= 0; i-- {
+		rule := current[i]
+		rules = append(rules, TemplateRule{
+			TriggerType: rule.TriggerType,
+			PrizeType:   rule.PrizeType,
+			PrizeName:   rule.PrizeName,
+			PrizeCents:  rule.PrizeCents,
+			Enabled:     rule.Enabled,
+			Weighting:   rule.Weighting,
+		})
+	}
+	return s.CreateRuleTemplate(ctx, streamerID, name, rules)
+}
+
+func (s *Service) applyRuleTemplate(ctx context.Context, tx pgx.Tx, streamerID, sessionID, templateID int64) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, `
+SELECT EXISTS(
+	SELECT 1 FROM giveaway_rule_templates
+	WHERE id = $1 AND streamer_id = $2
+)
+`, templateID, streamerID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("rule template not found")
+	}
+
+	_, err := tx.Exec(ctx, `
+INSERT INTO giveaway_rules (stream_session_id, trigger_type, prize_type, prize_name, prize_cents, enabled, weighting, weight_cap)
+SELECT $1, trigger_type, prize_type, prize_name, prize_cents, enabled, weighting, weight_cap
+FROM giveaway_rule_template_items
+WHERE template_id = $2
+ORDER BY position
+`, sessionID, templateID)
+	return err
+}
+
+func (s *Service) listTemplateRules(ctx context.Context, templateID int64) ([]TemplateRule, error) {
+	rows, err := s.db.Query(ctx, `
+SELECT trigger_type, prize_type, prize_name, prize_cents, enabled, weighting, weight_cap
+FROM giveaway_rule_template_items
+WHERE template_id = $1
+ORDER BY position
+`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]TemplateRule, 0)
+	for rows.Next() {
+		var rule TemplateRule
+		var strategy *string
+		var weightCap int64
+		if err := rows.Scan(&rule.TriggerType, &rule.PrizeType, &rule.PrizeName, &rule.PrizeCents, &rule.Enabled, &strategy, &weightCap); err != nil {
+			return nil, err
+		}
+		if strategy != nil {
+			rule.Weighting = &lottery.Weighting{Strategy: *strategy, Cap: weightCap}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func insertTemplateRules(ctx context.Context, tx pgx.Tx, templateID int64, rules []TemplateRule) error {
+	for i, rule := range rules {
+		var strategy *string
+		var weightCap int64
+		if rule.Weighting != nil {
+			strategy, weightCap = &rule.Weighting.Strategy, rule.Weighting.Cap
+		}
+		if _, err := tx.Exec(ctx, `
+INSERT INTO giveaway_rule_template_items (template_id, position, trigger_type, prize_type, prize_name, prize_cents, enabled, weighting, weight_cap)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+`, templateID, i, rule.TriggerType, rule.PrizeType, rule.PrizeName, rule.PrizeCents, rule.Enabled, strategy, weightCap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalizeTemplateRules(rules []TemplateRule) ([]TemplateRule, error) {
+	result := make([]TemplateRule, 0, len(rules))
+	for _, rule := range rules {
+		rule.TriggerType = strings.ToLower(strings.TrimSpace(rule.TriggerType))
+		rule.PrizeType = strings.ToLower(strings.TrimSpace(rule.PrizeType))
+		rule.PrizeName = strings.TrimSpace(rule.PrizeName)
+		if rule.TriggerType == "" || rule.PrizeName == "" {
+			return nil, errors.New("trigger_type and prize_name are required")
+		}
+		if rule.PrizeType != "skin" && rule.PrizeType != "case" {
+			return nil, errors.New("prize_type must be skin or case")
+		}
+		if rule.PrizeCents < 0 {
+			return nil, errors.New("prize_cents cannot be negative")
+		}
+		if rule.Weighting != nil {
+			weighting, err := lottery.NormalizeWeighting(*rule.Weighting)
+			if err != nil {
+				return nil, err
+			}
+			rule.Weighting = &weighting
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
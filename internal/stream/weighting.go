@@ -0,0 +1,70 @@
+package stream
+
+import (
+	"context"
+
+	"github.com/2006michigun2006-hub/cs2-livedrop/internal/lottery"
+)
+
+func (s *Service) SetSessionWeighting(ctx context.Context, actorID, sessionID int64, weighting lottery.Weighting) (lottery.Weighting, error) {
+	weighting, err := lottery.NormalizeWeighting(weighting)
+	if err != nil {
+		return lottery.Weighting{}, err
+	}
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageDraws)
+	if err != nil {
+		return lottery.Weighting{}, err
+	}
+
+	if _, err := s.db.Exec(ctx, `UPDATE stream_sessions SET weighting = $2, weight_cap = $3 WHERE id = $1`, sessionID, weighting.Strategy, weighting.Cap); err != nil {
+		return lottery.Weighting{}, err
+	}
+	s.auditSession(ctx, session, actorID, role, "set_weighting", map[string]interface{}{"strategy": weighting.Strategy, "cap": weighting.Cap})
+	return weighting, nil
+}
+
+func (s *Service) SetSessionWinLimits(ctx context.Context, actorID, sessionID int64, limits lottery.WinLimits) (lottery.WinLimits, error) {
+	limits, err := lottery.NormalizeWinLimits(limits)
+	if err != nil {
+		return lottery.WinLimits{}, err
+	}
+
+	session, role, err := s.authorizeSession(ctx, actorID, sessionID, permManageDraws)
+	if err != nil {
+		return lottery.WinLimits{}, err
+	}
+
+	if _, err := s.db.Exec(ctx, `
+UPDATE stream_sessions
+SET max_wins_per_hour = $2, max_wins_per_session = $3, max_win_value_cents = $4, win_limit_mode = $5
+WHERE id = $1
+`, sessionID, limits.MaxWinsPerHour, limits.MaxWinsPerSession, limits.MaxWinValueCents, limits.Mode); err != nil {
+		return lottery.WinLimits{}, err
+	}
+	s.auditSession(ctx, session, actorID, role, "set_win_limits", map[string]interface{}{
+		"max_wins_per_hour":    limits.MaxWinsPerHour,
+		"max_wins_per_session": limits.MaxWinsPerSession,
+		"max_win_value_cents":  limits.MaxWinValueCents,
+		"mode":                 limits.Mode,
+	})
+	return limits, nil
+}
+
+func (r GiveawayRule) drawWeighting(session Session) lottery.Weighting {
+	if r.Weighting != nil {
+		return *r.Weighting
+	}
+	return session.Weighting
+}
+
+func ruleWeighting(weighting *lottery.Weighting) (*string, int64, error) {
+	if weighting == nil {
+		return nil, 0, nil
+	}
+	normalized, err := lottery.NormalizeWeighting(*weighting)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &normalized.Strategy, normalized.Cap, nil
+}
@@ -3,6 +3,7 @@ package telegram
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-telegram/bot"
@@ -21,6 +23,10 @@ type BotClient struct {
 	token   string
 	baseURL string
 	bot     *bot.Bot
+
+	mu            sync.RWMutex
+	onChatMessage func(ctx context.Context, chatID, telegramUserID string)
+	onClaim       func(ctx context.Context, telegramUserID string, roundID int64) (string, error)
 }
 
 func NewBotClient(token, baseURL string) (*BotClient, error) {
@@ -33,7 +39,7 @@ func NewBotClient(token, baseURL string) (*BotClient, error) {
 		return client, nil
 	}
 
-	b, err := bot.New(client.token)
+	b, err := bot.New(client.token, bot.WithDefaultHandler(client.handleChatMessage))
 	if err != nil {
 		return nil, fmt.Errorf("telegram bot init failed: %w", err)
 	}
@@ -45,9 +51,11 @@ func NewBotClient(token, baseURL string) (*BotClient, error) {
 	startRegex := regexp.MustCompile(`^/start(?:\s+(.+))?$`)
 	inviteRegex := regexp.MustCompile(`^/invite\s+([A-Za-z0-9_-]+)$`)
 	simRegex := regexp.MustCompile(`^/sim(?:ulator)?\s+([A-Za-z0-9_-]+)$`)
+	claimRegex := regexp.MustCompile(`^/claim\s+(\d+)$`)
 	client.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, startRegex, client.handleStart)
 	client.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, inviteRegex, client.handleInviteCmd)
 	client.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, simRegex, client.handleSimulatorCmd)
+	client.bot.RegisterHandlerRegexp(bot.HandlerTypeMessageText, claimRegex, client.handleClaimCmd)
 	client.bot.RegisterHandler(bot.HandlerTypeMessageText, "/health", bot.MatchTypeExact, client.handleHealth)
 	client.bot.RegisterHandler(bot.HandlerTypeMessageText, "/debug", bot.MatchTypeExact, client.handleDebug)
 	client.bot.RegisterHandler(bot.HandlerTypeMessageText, "/help", bot.MatchTypeExact, client.handleHelp)
@@ -57,6 +65,31 @@ func NewBotClient(token, baseURL string) (*BotClient, error) {
 	return client, nil
 }
 
+func (b *BotClient) SetChatMessageHandler(fn func(ctx context.Context, chatID, telegramUserID string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChatMessage = fn
+}
+
+func (b *BotClient) SetClaimHandler(fn func(ctx context.Context, telegramUserID string, roundID int64) (string, error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onClaim = fn
+}
+
+func (b *BotClient) handleChatMessage(ctx context.Context, tg *bot.Bot, update *models.Update) {
+	b.mu.RLock()
+	onChatMessage := b.onChatMessage
+	b.mu.RUnlock()
+	if onChatMessage == nil || update == nil || update.Message == nil || update.Message.From == nil {
+		return
+	}
+	if update.Message.From.IsBot || strings.TrimSpace(update.Message.Text) == "" {
+		return
+	}
+	onChatMessage(ctx, strconv.FormatInt(update.Message.Chat.ID, 10), strconv.FormatInt(update.Message.From.ID, 10))
+}
+
 func (b *BotClient) ensureWebhook() error {
 	if b.token == "" || b.baseURL == "" {
 		return nil
@@ -112,6 +145,37 @@ func (b *BotClient) SendMessage(ctx context.Context, chatID, text string) error
 	return err
 }
 
+func (b *BotClient) IsChatMember(ctx context.Context, chatID, telegramUserID string) (bool, error) {
+	if b.bot == nil {
+		return false, errors.New("telegram bot is not configured")
+	}
+
+	chat, err := strconv.ParseInt(strings.TrimSpace(chatID), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid chat id: %w", err)
+	}
+	userID, err := strconv.ParseInt(strings.TrimSpace(telegramUserID), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid telegram user id: %w", err)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	member, err := b.bot.GetChatMember(reqCtx, &bot.GetChatMemberParams{ChatID: chat, UserID: userID})
+	if err != nil {
+		return false, err
+	}
+
+	switch member.Type {
+	case models.ChatMemberTypeOwner, models.ChatMemberTypeAdministrator, models.ChatMemberTypeMember:
+		return true, nil
+	case models.ChatMemberTypeRestricted:
+		return member.Restricted != nil && member.Restricted.IsMember, nil
+	default:
+		return false, nil
+	}
+}
+
 func (b *BotClient) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	if b.bot == nil {
 		log.Println("telegram webhook received but bot is not initialized")
@@ -203,6 +267,37 @@ func (b *BotClient) handleSimulatorCmd(ctx context.Context, tg *bot.Bot, update
 	}
 }
 
+func (b *BotClient) handleClaimCmd(ctx context.Context, tg *bot.Bot, update *models.Update) {
+	b.mu.RLock()
+	onClaim := b.onClaim
+	b.mu.RUnlock()
+	if update == nil || update.Message == nil || update.Message.From == nil || onClaim == nil {
+		return
+	}
+	parts := strings.Fields(strings.TrimSpace(update.Message.Text))
+	if len(parts) < 2 {
+		return
+	}
+	roundID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return
+	}
+
+	reply, err := onClaim(ctx, strconv.FormatInt(update.Message.From.ID, 10), roundID)
+	if err != nil {
+		reply = "Could not claim prize: " + err.Error()
+	}
+	sendCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = tg.SendMessage(sendCtx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   reply,
+	})
+	if err != nil {
+		log.Printf("telegram send /claim failed: %v", err)
+	}
+}
+
 func (b *BotClient) handleHealth(ctx context.Context, tg *bot.Bot, update *models.Update) {
 	if update == nil || update.Message == nil {
 		return
@@ -257,6 +352,7 @@ func (b *BotClient) handleHelp(ctx context.Context, tg *bot.Bot, update *models.
 			"/myid\n" +
 			"/invite CODE\n" +
 			"/sim CODE\n" +
+			"/claim ROUND_ID\n" +
 			"/start invite_CODE\n" +
 			"/health\n" +
 			"/debug",
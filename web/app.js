@@ -256,6 +256,7 @@ async function startStream(e) {
   const formData = new FormData(e.target);
   const payload = Object.fromEntries(formData.entries());
   payload.send_to_chat = !!payload.send_to_chat;
+  payload.replace = !!e.replaceLive;
   try {
     const data = await api("/api/streams/start", { method: "POST", body: JSON.stringify(payload) });
     state.activeSessionId = data.session.id;
@@ -271,6 +272,12 @@ async function startStream(e) {
       setStatus("Need streamer role. Click 'Enable Streamer Mode' then retry.", true);
       return;
     }
+    if (!payload.replace && String(err.message).includes("already have a live session")) {
+      if (confirm("You already have a live session. End it and start a new one?")) {
+        return startStream({ preventDefault() {}, target: e.target, replaceLive: true });
+      }
+      return;
+    }
     setStatus(err.message, true);
   }
 }
@@ -378,6 +385,19 @@ async function updateRule() {
   } catch (err) { setStatus(err.message, true); }
 }
 
+async function drawNow() {
+  const { sessionID, body } = readRulePayload();
+  if (!sessionID) return setStatus("Session ID required", true);
+  try {
+    const data = await api(`/api/streams/${sessionID}/draw`, {
+      method: "POST",
+      body: JSON.stringify({ prize_type: body.prize_type, prize_name: body.prize_name, prize_cents: body.prize_cents }),
+    });
+    setStatus(`Manual draw #${data.round.id}: winner user ${data.round.winner_user_id}.`);
+    await loadRounds();
+  } catch (err) { setStatus(err.message, true); }
+}
+
 async function deleteRule(ruleID) {
   const sessionID = Number(document.querySelector("#ruleForm input[name='session_id']").value || state.activeSessionId);
   if (!sessionID) return setStatus("Session ID required", true);
@@ -517,6 +537,7 @@ function bind() {
   document.getElementById("updateRule").addEventListener("click", updateRule);
   document.getElementById("loadActiveStream").addEventListener("click", loadActiveStream);
   document.getElementById("loadRules").addEventListener("click", loadRules);
+  document.getElementById("drawNow").addEventListener("click", drawNow);
   document.getElementById("refreshRounds").addEventListener("click", loadRounds);
   document.getElementById("refreshEvents").addEventListener("click", loadEvents);
   document.getElementById("enableStreamer").addEventListener("click", enableStreamerMode);
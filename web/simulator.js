@@ -11,6 +11,8 @@ const profileBalanceEl = document.getElementById("profileBalance");
 const caseWindow = document.getElementById("caseWindow");
 const caseReel = document.getElementById("caseReel");
 const openStatus = document.getElementById("openStatus");
+const multiOpenGrid = document.getElementById("multiOpenGrid");
+const openAllBtn = document.getElementById("openAllCases");
 const campaignStatusHint = document.getElementById("campaignStatusHint");
 const campaignRewardIcon = document.getElementById("campaignRewardIcon");
 const campaignRewardType = document.getElementById("campaignRewardType");
@@ -24,10 +26,18 @@ const crowdfundingSection = document.getElementById("crowdfundingSection");
 const specialDropOverlay = document.getElementById("specialDropOverlay");
 const specialDropSkull = document.getElementById("specialDropSkull");
 const eventToast = document.getElementById("eventToast");
+const historyList = document.getElementById("historyList");
+const historyMoreBtn = document.getElementById("historyMore");
+const claimsPanel = document.getElementById("claimsPanel");
+const claimsList = document.getElementById("claimsList");
+const storePanel = document.getElementById("storePanel");
+const storeList = document.getElementById("storeList");
 
 const CARD_WIDTH = 192;
 const WINNER_INDEX = 40;
 const TOTAL_CARDS = 50;
+const MAX_BULK_OPEN = 50;
+const MULTI_REVEAL_MS = 220;
 
 const CRATES_URL = "https://raw.githubusercontent.com/ByMykel/CSGO-API/main/public/api/en/crates.json";
 const SKINS_URL = "https://raw.githubusercontent.com/ByMykel/CSGO-API/main/public/api/en/skins.json";
@@ -62,6 +72,8 @@ const state = {
   lastCampaignID: null,
   lastCampaignStatus: "",
   lastClosedToastKey: "",
+  userID: null,
+  historyBefore: null,
 };
 
 function setStatus(message, isError = false) {
@@ -184,12 +196,22 @@ async function loadCS2Data() {
   }
 }
 
+async function sendPresence() {
+  if (!state.invite) return;
+  try {
+    await api(`/api/streams/presence/${encodeURIComponent(state.invite)}`, { method: "POST", body: "{}" });
+  } catch {
+    // stream ended or viewer not in the pool
+  }
+}
+
 async function ensureJoined() {
   if (!state.invite) return;
   try {
     await api(`/api/streams/join/${encodeURIComponent(state.invite)}`, { method: "POST", body: "{}" });
-  } catch {
+  } catch (err) {
     // user may already be joined or no active stream
+    if (String(err.message || "").startsWith("not eligible")) setStatus(err.message, true);
   }
 }
 
@@ -244,6 +266,9 @@ function resolveRarityColor(item) {
 
 function renderInventory(items) {
   inventoryGrid.innerHTML = "";
+  const unopened = items.filter((item) => item.item_type === "case" && item.status === "unopened").length;
+  openAllBtn.style.display = unopened > 1 ? "inline-block" : "none";
+  openAllBtn.textContent = `Open all (${Math.min(unopened, MAX_BULK_OPEN)})`;
 
   if (!items.length) {
     inventoryGrid.innerHTML = "<p style='grid-column:1/-1;text-align:center;color:#68788a;'>No rewards yet. Wait for stream triggers.</p>";
@@ -266,10 +291,11 @@ function renderInventory(items) {
     }
 
     const title = document.createElement("h3");
-    title.textContent = item.name;
+    title.textContent = item.stattrak ? `StatTrak™ ${item.name}` : item.name;
 
     const meta = document.createElement("p");
-    meta.textContent = `${item.status} | market ${formatUSD(item.price_cents)}`;
+    const wear = describeWear(item);
+    meta.textContent = `${wear ? `${wear} | ` : ""}${item.status} | market ${formatUSD(item.price_cents)}`;
 
     card.appendChild(title);
     card.appendChild(meta);
@@ -303,6 +329,12 @@ function renderInventory(items) {
   }
 }
 
+function describeWear(item) {
+  if (!item || !item.wear) return "";
+  if (typeof item.float_value !== "number") return item.wear;
+  return `${item.wear} (${item.float_value.toFixed(4)})`;
+}
+
 function renderCampaign(view) {
   if (!view || !view.case || view.case.status !== "open") {
     const closedKey = view?.case ? `${view.case.id}:${view.case.status}` : "";
@@ -395,10 +427,152 @@ async function loadInventory() {
   }
 }
 
+async function loadHistory(append = false) {
+  try {
+    const before = append && state.historyBefore ? `&before=${state.historyBefore}` : "";
+    const data = await api(`/api/lottery/rounds/me?limit=20${before}`);
+    renderHistory(data.rounds || [], append);
+    state.historyBefore = data.next_before || null;
+    historyMoreBtn.style.display = state.historyBefore ? "inline-flex" : "none";
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+function renderHistory(rounds, append) {
+  if (!append) historyList.innerHTML = "";
+  if (!rounds.length && !append) {
+    historyList.innerHTML = "<li class='history-empty'>No giveaways yet.</li>";
+    return;
+  }
+
+  for (const round of rounds) {
+    const won = round.winner_user_id === state.userID;
+    const details = round.details || {};
+    const prize = details.prize_name || (round.prize_cents ? formatUSD(round.prize_cents) : round.trigger_type);
+    const li = document.createElement("li");
+    li.className = won ? "history-item won" : "history-item";
+
+    const title = document.createElement("strong");
+    title.textContent = won ? `You won ${prize}` : `${round.winner_name || "Someone"} won ${prize}`;
+    const meta = document.createElement("span");
+    meta.textContent = `${round.trigger_type} | ${new Date(round.created_at).toLocaleString()}`;
+
+    li.appendChild(title);
+    li.appendChild(meta);
+    historyList.appendChild(li);
+  }
+}
+
+async function loadClaims() {
+  try {
+    const data = await api("/api/lottery/claims/me");
+    renderClaims(data.claims || []);
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+function renderClaims(claims) {
+  claimsList.innerHTML = "";
+  claimsPanel.style.display = claims.length ? "block" : "none";
+
+  for (const round of claims) {
+    const details = round.details || {};
+    const prize = details.prize_name || (round.prize_cents ? formatUSD(round.prize_cents) : round.trigger_type);
+    const li = document.createElement("li");
+    li.className = "history-item won";
+
+    const title = document.createElement("strong");
+    title.textContent = `You won ${prize}`;
+    const meta = document.createElement("span");
+    meta.textContent = `claim before ${new Date(round.claim_expires_at).toLocaleTimeString()}`;
+    const btn = document.createElement("button");
+    btn.className = "claim-btn";
+    btn.textContent = "Claim";
+    btn.addEventListener("click", () => claimPrize(round.id, btn));
+
+    li.appendChild(title);
+    li.appendChild(meta);
+    li.appendChild(btn);
+    claimsList.appendChild(li);
+  }
+}
+
+async function claimPrize(roundID, btn) {
+  btn.disabled = true;
+  try {
+    await api(`/api/lottery/claims/${roundID}`, { method: "POST" });
+    showToast("Prize claimed.");
+    await Promise.all([loadClaims(), loadInventory(), loadHistory()]);
+  } catch (err) {
+    btn.disabled = false;
+    setStatus(err.message, true);
+  }
+}
+
+async function loadStore() {
+  try {
+    const data = await api("/api/store/cases");
+    renderStore(data.cases || []);
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+function renderStore(cases) {
+  storeList.innerHTML = "";
+  storePanel.style.display = cases.length ? "block" : "none";
+
+  for (const entry of cases) {
+    const li = document.createElement("li");
+    li.className = "history-item";
+
+    const title = document.createElement("strong");
+    title.textContent = entry.name;
+    const meta = document.createElement("span");
+    meta.textContent = entry.requires_key
+      ? `needs ${entry.key_name}${entry.key_price_cents ? ` (${formatUSD(entry.key_price_cents)})` : ""}`
+      : "no key needed";
+    li.appendChild(title);
+    li.appendChild(meta);
+
+    const buyBtn = document.createElement("button");
+    buyBtn.className = "store-btn";
+    buyBtn.textContent = `Buy ${formatUSD(entry.price_cents)}`;
+    buyBtn.addEventListener("click", () => buyFromStore(`/api/store/cases/${entry.id}/buy`, entry.name, buyBtn));
+    li.appendChild(buyBtn);
+
+    if (entry.requires_key && entry.key_price_cents > 0) {
+      const keyBtn = document.createElement("button");
+      keyBtn.className = "store-btn ghost";
+      keyBtn.textContent = `Key ${formatUSD(entry.key_price_cents)}`;
+      keyBtn.addEventListener("click", () => buyFromStore(`/api/store/cases/${entry.id}/keys`, entry.key_name, keyBtn));
+      li.appendChild(keyBtn);
+    }
+    storeList.appendChild(li);
+  }
+}
+
+async function buyFromStore(path, name, btn) {
+  btn.disabled = true;
+  try {
+    const data = await api(path, { method: "POST", body: JSON.stringify({ quantity: 1 }) });
+    setStatus(`Bought ${name}. New balance: ${formatUSD(data.balance_cents)}.`);
+    await loadProfile();
+    await loadInventory();
+  } catch (err) {
+    setStatus(err.message, true);
+  } finally {
+    btn.disabled = false;
+  }
+}
+
 async function loadProfile() {
   try {
     const data = await api("/api/auth/me");
     const user = data.user;
+    state.userID = user ? user.id : null;
     setProfile(user);
     if (!state.token) {
       state.token = localStorage.getItem("jwt") || null;
@@ -555,6 +729,8 @@ async function openCase(caseItem) {
     openStatus.textContent = "Opening...";
     openStatus.style.color = "#e4ae39";
     caseWindow.classList.add("active");
+    caseWindow.style.display = "";
+    multiOpenGrid.classList.remove("active");
     caseReel.innerHTML = "";
     caseReel.style.transition = "none";
     caseReel.style.transform = "translateX(0px)";
@@ -609,7 +785,8 @@ async function openCase(caseItem) {
       } else {
         playRevealSound(winnerDrop.rarity);
       }
-      openStatus.textContent = `You got: ${winnerDrop.name}`;
+      const wear = describeWear(winnerDrop);
+      openStatus.textContent = `You got: ${winnerDrop.stattrak ? "StatTrak™ " : ""}${winnerDrop.name}${wear ? ` - ${wear}` : ""}`;
       openStatus.style.color = "#fff";
       await loadInventory();
     }, duration);
@@ -632,6 +809,54 @@ async function logout() {
   setStatus("Logged out.");
 }
 
+async function openAllCases() {
+  openAllBtn.disabled = true;
+  try {
+    openStatus.textContent = "Opening...";
+    openStatus.style.color = "#e4ae39";
+    caseWindow.classList.remove("active");
+    caseWindow.style.display = "none";
+    multiOpenGrid.innerHTML = "";
+
+    const data = await api("/api/inventory/open-bulk", {
+      method: "POST",
+      body: JSON.stringify({ count: MAX_BULK_OPEN }),
+    });
+    const drops = data.drops || [];
+    multiOpenGrid.classList.add("active");
+
+    const cards = drops.map((drop) => {
+      const card = createReelCard({ name: drop.name, image: resolveItemImage(drop), rarity: drop.rarity });
+      card.classList.add("multi-hidden");
+      multiOpenGrid.appendChild(card);
+      return card;
+    });
+
+    let best = null;
+    drops.forEach((drop, i) => {
+      if (isBestDropRarity(drop.rarity)) best = drop;
+      setTimeout(() => {
+        cards[i].classList.remove("multi-hidden");
+        if (!isBestDropRarity(drop.rarity)) playRevealSound(drop.rarity);
+      }, (i + 1) * MULTI_REVEAL_MS);
+    });
+
+    setTimeout(async () => {
+      if (best) playSpecialDropFx();
+      const total = drops.reduce((sum, drop) => sum + (drop.price_cents || 0), 0);
+      openStatus.textContent = `You got ${drops.length} items worth ${formatUSD(total)}`;
+      openStatus.style.color = "#fff";
+      await loadInventory();
+    }, (drops.length + 1) * MULTI_REVEAL_MS);
+  } catch (err) {
+    setStatus(err.message, true);
+    caseWindow.style.display = "";
+    openStatus.textContent = "Error opening cases.";
+  } finally {
+    openAllBtn.disabled = false;
+  }
+}
+
 async function sellItem(item) {
   try {
     const data = await api(`/api/inventory/sell/${item.id}`, { method: "POST", body: "{}" });
@@ -661,17 +886,23 @@ async function init() {
   const authed = await loadProfile();
   if (authed) {
     await ensureJoined();
-    await Promise.all([loadInventory(), loadCampaign()]);
+    await Promise.all([loadInventory(), loadCampaign(), loadHistory(), loadClaims(), loadStore()]);
     setInterval(() => {
       if (!openStatus.textContent || openStatus.textContent.startsWith("You got")) {
         loadInventory();
       }
       loadCampaign();
+      loadClaims();
     }, 10000);
+    sendPresence();
+    setInterval(sendPresence, 30000);
   }
 }
 
 document.getElementById("refreshInventory").addEventListener("click", loadInventory);
+openAllBtn.addEventListener("click", openAllCases);
+document.getElementById("refreshHistory").addEventListener("click", () => loadHistory());
+historyMoreBtn.addEventListener("click", () => loadHistory(true));
 logoutBtn.addEventListener("click", logout);
 campaignDonateBtn.addEventListener("click", donateCampaign);
 